@@ -0,0 +1,307 @@
+package nmap
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidChunkSize means that the chunk size given to RunChunked is not strictly positive.
+	ErrInvalidChunkSize = errors.New("chunk size should be strictly positive")
+
+	// ErrInvalidAttempts means that the number of attempts given to RunWithRetries is not strictly positive.
+	ErrInvalidAttempts = errors.New("number of attempts should be strictly positive")
+)
+
+// RunChunked splits the scanner's targets into chunks of chunkSize targets
+// and runs one nmap scan per chunk sequentially, using the given context.
+// The results of all chunks are merged into a single Run using MergeRuns.
+// If a chunk exceeds its timeout, the remaining chunks are still run, and the
+// timeout errors are returned along with the results of the other chunks.
+// If a chunk fails for any other reason, the results of the previous chunks
+// are returned along with the error.
+// Async mode and progress streaming are not supported when running chunks.
+// When used along with ToFile, the file only contains the output of the last chunk.
+// When used along with WithTimeout, the timeout applies to each chunk rather than
+// to the whole run.
+func (s *Scanner) RunChunked(ctx context.Context, chunkSize int) (result *Run, warnings []string, err error) {
+	if chunkSize <= 0 {
+		return nil, nil, ErrInvalidChunkSize
+	}
+
+	var runs []*Run
+	var timeoutErrs []error
+	for _, targets := range chunkTargets(s.targets, chunkSize) {
+		chunk := *s
+		chunk.ctx = ctx
+		chunk.targets = targets
+		chunk.doneAsync = nil
+		chunk.liveProgress = nil
+		chunk.liveETA = nil
+
+		run, chunkWarnings, err := chunk.Run()
+		warnings = append(warnings, *chunkWarnings...)
+		if errors.Is(err, ErrScanTimeout) && ctx.Err() == nil {
+			timeoutErrs = append(timeoutErrs, fmt.Errorf("chunk %v: %w", targets, err))
+			continue
+		}
+		if err != nil {
+			return MergeRuns(runs...), warnings, errors.Join(append(timeoutErrs, err)...)
+		}
+
+		runs = append(runs, run)
+	}
+
+	return MergeRuns(runs...), warnings, errors.Join(timeoutErrs...)
+}
+
+// RunWithRetries runs the scan using the given context until it succeeds, at most
+// attempts times, and returns the result and warnings of the last attempt along with
+// its error. If maxTotalDuration is strictly positive, it bounds the time spent over
+// all attempts: no attempt is started once it is exceeded, and the running attempt
+// is stopped with ErrScanTimeout when it is reached. Interrupted scans and cancelled
+// contexts are never retried.
+// Async mode and progress streaming are not supported when retrying scans.
+func (s *Scanner) RunWithRetries(ctx context.Context, attempts int, maxTotalDuration time.Duration) (result *Run, warnings []string, err error) {
+	if attempts <= 0 {
+		return nil, nil, ErrInvalidAttempts
+	}
+
+	if maxTotalDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxTotalDuration)
+		defer cancel()
+	}
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		retry := *s
+		retry.ctx = ctx
+		retry.doneAsync = nil
+		retry.liveProgress = nil
+		retry.liveETA = nil
+
+		var attemptWarnings *[]string
+		result, attemptWarnings, err = retry.Run()
+		warnings = *attemptWarnings
+		if err == nil || ctx.Err() != nil || errors.Is(err, ErrScanInterrupt) {
+			return result, warnings, err
+		}
+	}
+
+	return result, warnings, err
+}
+
+// RunAll runs the given scanners using at most concurrency scans at once, and
+// returns their results and errors, indexed like the scanners. A failing scan
+// does not abort the others. The given context replaces the context of each
+// scanner, so that cancelling it stops the running scans and prevents the
+// remaining ones from starting. If concurrency is not strictly positive, all
+// scanners run at once.
+// Scanners are always run synchronously, even if Async was used on them.
+func RunAll(ctx context.Context, scanners []*Scanner, concurrency int) ([]*Run, []error) {
+	results := make([]*Run, len(scanners))
+	errs := make([]error, len(scanners))
+
+	if concurrency <= 0 || concurrency > len(scanners) {
+		concurrency = len(scanners)
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for idx, scanner := range scanners {
+		if ctx.Err() != nil {
+			errs[idx] = ctx.Err()
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			errs[idx] = ctx.Err()
+			continue
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(idx int, scanner Scanner) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			scanner.ctx = ctx
+			scanner.doneAsync = nil
+
+			results[idx], _, errs[idx] = scanner.Run()
+		}(idx, *scanner)
+	}
+
+	wg.Wait()
+
+	return results, errs
+}
+
+// chunkTargets splits the targets into chunks of at most size targets.
+// It always returns at least one chunk, so that scans without targets
+// (for example when using WithTargetInput) still run once.
+func chunkTargets(targets []string, size int) [][]string {
+	if len(targets) == 0 {
+		return [][]string{nil}
+	}
+
+	var chunks [][]string
+	for start := 0; start < len(targets); start += size {
+		end := start + size
+		if end > len(targets) {
+			end = len(targets)
+		}
+		chunks = append(chunks, targets[start:end])
+	}
+
+	return chunks
+}
+
+// MergeRuns merges multiple runs into a single one. The scan information
+// of the first run is kept, as well as the first scan ID set using
+// WithScanID, while the hosts, targets, tasks, scripts and warnings of all
+// runs are gathered. The host statistics and elapsed time are summed up,
+// and the start and finish times span across all runs.
+// The merged run has no raw XML, since it was not produced by nmap.
+func MergeRuns(runs ...*Run) *Run {
+	merged := &Run{}
+
+	first := true
+	for _, run := range runs {
+		if run == nil {
+			continue
+		}
+
+		if first {
+			first = false
+			merged.XMLName = run.XMLName
+			merged.Args = run.Args
+			merged.ProfileName = run.ProfileName
+			merged.Scanner = run.Scanner
+			merged.StartStr = run.StartStr
+			merged.Version = run.Version
+			merged.XMLOutputVersion = run.XMLOutputVersion
+			merged.Debugging = run.Debugging
+			merged.Stats = run.Stats
+			merged.ScanInfo = run.ScanInfo
+			merged.ScanInfos = append([]ScanInfo(nil), run.ScanInfos...)
+			merged.Start = run.Start
+			merged.Verbose = run.Verbose
+		} else {
+			mergeStats(merged, run)
+		}
+
+		merged.Hosts = append(merged.Hosts, run.Hosts...)
+		merged.Targets = append(merged.Targets, run.Targets...)
+		merged.PreScripts = append(merged.PreScripts, run.PreScripts...)
+		merged.PostScripts = append(merged.PostScripts, run.PostScripts...)
+		merged.TaskBegin = append(merged.TaskBegin, run.TaskBegin...)
+		merged.TaskProgress = append(merged.TaskProgress, run.TaskProgress...)
+		merged.TaskEnd = append(merged.TaskEnd, run.TaskEnd...)
+		merged.NmapErrors = append(merged.NmapErrors, run.NmapErrors...)
+		if merged.scanID == "" {
+			merged.scanID = run.scanID
+		}
+
+		merged.warnings = append(merged.warnings, run.warnings...)
+		merged.scriptTrace = append(merged.scriptTrace, run.scriptTrace...)
+	}
+
+	return merged
+}
+
+// mergeStats adds the statistics and timing of a run into the merged run.
+func mergeStats(merged, run *Run) {
+	merged.Stats.Hosts.Up += run.Stats.Hosts.Up
+	merged.Stats.Hosts.Down += run.Stats.Hosts.Down
+	merged.Stats.Hosts.Total += run.Stats.Hosts.Total
+	merged.Stats.Finished.Elapsed += run.Stats.Finished.Elapsed
+
+	start := time.Time(run.Start)
+	if !start.IsZero() && (time.Time(merged.Start).IsZero() || start.Before(time.Time(merged.Start))) {
+		merged.Start = run.Start
+		merged.StartStr = run.StartStr
+	}
+
+	if time.Time(run.Stats.Finished.Time).After(time.Time(merged.Stats.Finished.Time)) {
+		merged.Stats.Finished.Time = run.Stats.Finished.Time
+		merged.Stats.Finished.TimeStr = run.Stats.Finished.TimeStr
+	}
+
+	// Keep the first scan error that was encountered.
+	if merged.Stats.Finished.ErrorMsg == "" {
+		merged.Stats.Finished.Exit = run.Stats.Finished.Exit
+		merged.Stats.Finished.ErrorMsg = run.Stats.Finished.ErrorMsg
+	}
+}
+
+// ResumeAndMerge resumes an aborted scan, and merges the hosts already found in
+// the XML output of the aborted scan with the ones found by the resumed scan, to
+// get a complete result. The scanner should be resuming the aborted scan using
+// WithResumePreviousScan, and previousXML is the path of the XML output of the
+// aborted scan, which may be truncated since the scan was interrupted. Only the
+// hosts which were fully written to it are kept.
+// Since nmap doesn't accept any other option when resuming a scan, it is run with
+// --resume only, and its other options are ignored. Nmap takes the options of the
+// aborted scan from its log and appends the results of the resumed scan to the
+// same output files, so they are read back from previousXML once it is over.
+// The resumed scan is bound to the given context instead of the scanner's, and
+// always runs synchronously.
+func (s *Scanner) ResumeAndMerge(ctx context.Context, previousXML string) (*Run, error) {
+	logFile, found := s.argValue("--resume")
+	if !found {
+		return nil, fmt.Errorf("%w: resuming a scan requires WithResumePreviousScan", ErrInvalidOption)
+	}
+
+	before, err := os.ReadFile(previousXML)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := &Run{}
+	if err := Parse(before, previous); err != nil {
+		var parseErr *ErrParse
+		if !errors.As(err, &parseErr) {
+			return nil, err
+		}
+	}
+
+	resumed := &Scanner{
+		binaryPath:      s.binaryPath,
+		ctx:             ctx,
+		args:            []string{"--resume", logFile},
+		unmanagedOutput: true,
+	}
+
+	result, _, err := resumed.Run()
+	if err != nil {
+		return result, err
+	}
+
+	after, err := os.ReadFile(previousXML)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only parse what the resumed scan appended to the output of the aborted one.
+	appended := after
+	if bytes.HasPrefix(after, before) {
+		appended = after[len(before):]
+	}
+
+	next := &Run{}
+	if err := Parse(appended, next); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParseOutput, err)
+	}
+	next.warnings = result.warnings
+	next.scanID = s.scanID
+
+	return MergeRuns(previous, next), nil
+}
@@ -0,0 +1,397 @@
+package nmap
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunChunked(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_targets.sh"),
+		WithTargets("192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4", "192.168.0.5"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	result, warnings, err := s.RunChunked(context.TODO(), 2)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+
+	var addresses []string
+	for _, host := range result.Hosts {
+		addresses = append(addresses, host.Addresses[0].Addr)
+	}
+	assert.Equal(t, []string{"192.168.0.1", "192.168.0.2", "192.168.0.3", "192.168.0.4", "192.168.0.5"}, addresses)
+
+	assert.Equal(t, 5, result.Stats.Hosts.Up)
+	assert.Equal(t, 5, result.Stats.Hosts.Total)
+	assert.Equal(t, float32(4.5), result.Stats.Finished.Elapsed)
+
+	// The scanner's own targets are left untouched.
+	assert.Len(t, s.targets, 5)
+}
+
+func TestRunChunkedWithTimeout(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_targets_hang.sh"),
+		WithTimeout(500*time.Millisecond),
+		WithTargets("192.168.0.1", "192.168.0.2", "192.168.0.3"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	// The chunk which hangs is cancelled, and the remaining chunks still run.
+	result, _, err := s.RunChunked(context.TODO(), 1)
+	assert.ErrorIs(t, err, ErrScanTimeout)
+	assert.ErrorContains(t, err, "192.168.0.2")
+
+	var addresses []string
+	for _, host := range result.Hosts {
+		addresses = append(addresses, host.Addresses[0].Addr)
+	}
+	assert.Equal(t, []string{"192.168.0.1", "192.168.0.3"}, addresses)
+}
+
+func TestRunChunkedPerHostTimeout(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+	}{
+		{
+			description: "target range",
+
+			options: []Option{WithTargets("10.0.0.0/24")},
+		},
+		{
+			description: "targets from an input file",
+
+			options: []Option{WithTargetInput("/targets.txt")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			options := append([]Option{
+				WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+				WithCustomArguments("0.5", "tests/xml/scan_base.xml"),
+				WithPerHostTimeout(100 * time.Millisecond),
+			}, test.options...)
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err)
+			}
+
+			// Nmap enforces the per-host timeout itself, so chunks aren't given a deadline.
+			_, _, err = s.RunChunked(context.TODO(), 1)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRunChunkedInvalidSize(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_targets.sh"),
+		WithTargets("192.168.0.1"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	_, _, err = s.RunChunked(context.TODO(), 0)
+	assert.ErrorIs(t, err, ErrInvalidChunkSize)
+}
+
+func TestRunWithRetries(t *testing.T) {
+	tests := []struct {
+		description string
+
+		failures int
+		attempts int
+
+		expectedRuns int
+		expectedErr  bool
+	}{
+		{
+			description: "succeeds on first attempt",
+
+			failures: 0,
+			attempts: 3,
+
+			expectedRuns: 1,
+		},
+		{
+			description: "succeeds after retries",
+
+			failures: 2,
+			attempts: 3,
+
+			expectedRuns: 3,
+		},
+		{
+			description: "runs out of attempts",
+
+			failures: 5,
+			attempts: 3,
+
+			expectedRuns: 3,
+			expectedErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var runs int
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_flaky.sh"),
+				WithCustomArguments(filepath.Join(t.TempDir(), "state"), strconv.Itoa(test.failures), "tests/xml/scan_base.xml"),
+				WithBeforeRun(func([]string) {
+					runs++
+				}),
+			)
+			if err != nil {
+				panic(err)
+			}
+
+			result, warnings, err := s.RunWithRetries(context.TODO(), test.attempts, 0)
+			assert.Equal(t, test.expectedRuns, runs)
+			if test.expectedErr {
+				var exitErr *exec.ExitError
+				assert.ErrorAs(t, err, &exitErr)
+				assert.Equal(t, []string{"Temporary failure"}, warnings)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NotEmpty(t, result.Hosts)
+		})
+	}
+}
+
+func TestRunWithRetriesBudget(t *testing.T) {
+	var runs int
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+		WithCustomArguments("0.1", "tests/xml/does_not_exist.xml"),
+		WithBeforeRun(func([]string) {
+			runs++
+		}),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	_, _, err = s.RunWithRetries(context.TODO(), 1000, 500*time.Millisecond)
+	assert.Error(t, err)
+	assert.Greater(t, runs, 1, "expected the scan to be retried")
+	assert.Less(t, runs, 1000, "expected retries to stop once the budget is exceeded")
+}
+
+func TestRunWithRetriesInvalidAttempts(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap.sh"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	_, _, err = s.RunWithRetries(context.TODO(), 0, time.Second)
+	assert.ErrorIs(t, err, ErrInvalidAttempts)
+}
+
+func TestChunkTargets(t *testing.T) {
+	assert.Equal(t, [][]string{nil}, chunkTargets(nil, 3))
+	assert.Equal(t, [][]string{{"a", "b", "c"}}, chunkTargets([]string{"a", "b", "c"}, 3))
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, chunkTargets([]string{"a", "b", "c"}, 2))
+}
+
+func TestMergeRuns(t *testing.T) {
+	first := &Run{
+		Scanner: "nmap",
+		Start:   Timestamp(time.Unix(200, 0)),
+		Stats: Stats{
+			Finished: Finished{Time: Timestamp(time.Unix(300, 0)), Elapsed: 100},
+			Hosts:    HostStats{Up: 1, Down: 1, Total: 2},
+		},
+		Hosts:    []Host{{Addresses: []Address{{Addr: "10.0.0.1"}}}},
+		warnings: []string{"first warning"},
+		rawXML:   []byte("<nmaprun></nmaprun>"),
+	}
+	second := &Run{
+		Scanner: "nmap",
+		Start:   Timestamp(time.Unix(100, 0)),
+		Stats: Stats{
+			Finished: Finished{Time: Timestamp(time.Unix(400, 0)), Elapsed: 50},
+			Hosts:    HostStats{Up: 2, Down: 0, Total: 2},
+		},
+		Hosts:    []Host{{Addresses: []Address{{Addr: "10.0.0.2"}}}, {Addresses: []Address{{Addr: "10.0.0.3"}}}},
+		warnings: []string{"second warning"},
+		scanID:   "scan-42",
+	}
+
+	merged := MergeRuns(first, nil, second)
+
+	assert.Equal(t, "nmap", merged.Scanner)
+	assert.Len(t, merged.Hosts, 3)
+	assert.Equal(t, HostStats{Up: 3, Down: 1, Total: 4}, merged.Stats.Hosts)
+	assert.Equal(t, float32(150), merged.Stats.Finished.Elapsed)
+	assert.Equal(t, time.Unix(100, 0), time.Time(merged.Start))
+	assert.Equal(t, time.Unix(400, 0), time.Time(merged.Stats.Finished.Time))
+	assert.Equal(t, []string{"first warning", "second warning"}, merged.Warnings())
+	assert.Nil(t, merged.rawXML)
+	assert.Equal(t, "scan-42", merged.ScanID())
+
+	// Merging must not alter the given runs.
+	assert.Len(t, first.Hosts, 1)
+	assert.Equal(t, HostStats{Up: 1, Down: 1, Total: 2}, first.Stats.Hosts)
+}
+
+func TestRunAll(t *testing.T) {
+	var scanners []*Scanner
+	for i := 0; i < 4; i++ {
+		s, err := NewScanner(
+			context.TODO(),
+			WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+			WithCustomArguments("0.2", "tests/xml/scan_base.xml"),
+		)
+		if err != nil {
+			panic(err)
+		}
+		scanners = append(scanners, s)
+	}
+
+	failing, err := NewScanner(context.TODO(), WithBinaryPath("/invalid"))
+	if err != nil {
+		panic(err)
+	}
+	scanners = append(scanners, failing)
+
+	start := time.Now()
+	results, errs := RunAll(context.TODO(), scanners, 2)
+	elapsed := time.Since(start)
+
+	assert.Len(t, results, 5)
+	assert.Len(t, errs, 5)
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, errs[i])
+		assert.Len(t, results[i].Hosts, 1)
+	}
+	assert.Error(t, errs[4])
+
+	// With 4 scans of 200ms and only 2 at once, the batch takes at least 400ms.
+	assert.GreaterOrEqual(t, elapsed, 400*time.Millisecond)
+}
+
+func TestRunAllCancelled(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+		WithCustomArguments("0", "tests/xml/scan_base.xml"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, errs := RunAll(ctx, []*Scanner{s, s}, 1)
+
+	assert.Equal(t, []*Run{nil, nil}, results)
+	assert.ErrorIs(t, errs[0], context.Canceled)
+	assert.ErrorIs(t, errs[1], context.Canceled)
+}
+
+func TestResumeAndMerge(t *testing.T) {
+	// Nmap resumes into the output files of the aborted scan, which are listed in its log.
+	dir := t.TempDir()
+	previousXML := filepath.Join(dir, "scan_resume_previous.xml")
+	logFile := filepath.Join(dir, "scan_interrupted.nmap")
+
+	previous, err := os.ReadFile("tests/xml/scan_resume_previous.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(previousXML, previous, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := os.ReadFile("tests/resume/scan_interrupted.nmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log = bytes.Replace(log, []byte("-oX scan_resume_previous.xml"), []byte("-oX "+previousXML), 1)
+	if err := os.WriteFile(logFile, log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_resume.sh"),
+		WithResumePreviousScan(logFile),
+		WithScanID("resumed"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, err := s.ResumeAndMerge(context.TODO(), previousXML)
+	assert.NoError(t, err)
+
+	var addrs []string
+	for _, host := range result.Hosts {
+		addrs = append(addrs, host.Addresses[0].Addr)
+	}
+	assert.Equal(t, []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"}, addrs)
+
+	// The scan information of the aborted scan is kept, and the finish time of the resumed one.
+	assert.Equal(t, "nmap -oX scan_resume_previous.xml -oN scan_interrupted.nmap -p 22,80 192.168.1.0/24", result.Args)
+	assert.Equal(t, time.Unix(1684339800, 0), result.StartedAt())
+	assert.Equal(t, time.Unix(1684343430, 0), result.FinishedAt())
+	assert.Equal(t, "resumed", result.ScanID())
+}
+
+func TestResumeAndMergeWithoutResume(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_resume.sh"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, err = s.ResumeAndMerge(context.TODO(), "tests/xml/scan_resume_previous.xml")
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestResumeAndMergeMissingPreviousXML(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_resume.sh"),
+		WithResumePreviousScan("tests/resume/scan_interrupted.nmap"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, err = s.ResumeAndMerge(context.TODO(), "tests/xml/does_not_exist.xml")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,312 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCommand means that the command given to ParseCommand can't be parsed.
+var ErrInvalidCommand = errors.New("invalid nmap command")
+
+// commandFlags associates the nmap flags which don't take a value with their options.
+var commandFlags = map[string]func() Option{
+	"-sL":             WithListScan,
+	"-sn":             WithPingScan,
+	"-Pn":             WithSkipHostDiscovery,
+	"-PE":             WithICMPEchoDiscovery,
+	"-PP":             WithICMPTimestampDiscovery,
+	"-PM":             WithICMPNetMaskDiscovery,
+	"-n":              WithDisabledDNSResolution,
+	"-R":              WithForcedDNSResolution,
+	"--system-dns":    WithSystemDNS,
+	"--traceroute":    WithTraceRoute,
+	"-sS":             WithSYNScan,
+	"-sT":             WithConnectScan,
+	"-sA":             WithACKScan,
+	"-sW":             WithWindowScan,
+	"-sM":             WithMaimonScan,
+	"-sU":             WithUDPScan,
+	"-sN":             WithTCPNullScan,
+	"-sF":             WithTCPFINScan,
+	"-sX":             WithTCPXmasScan,
+	"-sY":             WithSCTPInitScan,
+	"-sZ":             WithSCTPCookieEchoScan,
+	"-sO":             WithIPProtocolScan,
+	"-F":              WithFastMode,
+	"-r":              WithConsecutivePortScanning,
+	"-sV":             WithServiceInfo,
+	"--version-light": WithVersionLight,
+	"--version-all":   WithVersionAll,
+	"--version-trace": WithVersionTrace,
+	"-sC":             WithDefaultScript,
+	"--script-trace":  WithScriptTrace,
+	"-O":              WithOSDetection,
+	"--osscan-limit":  WithOSScanLimit,
+	"--osscan-guess":  WithOSScanGuess,
+	"-f":              WithFragmentPackets,
+	"--badsum":        WithBadSum,
+	"--reason":        WithReason,
+	"--open":          WithOpenOnly,
+	"--packet-trace":  WithPacketTrace,
+	"--append-output": WithAppendOutput,
+	"--webxml":        WithWebXML,
+	"--no-stylesheet": WithNoStylesheet,
+	"-6":              WithIPv6Scanning,
+	"-A":              WithAggressiveScan,
+	"--send-eth":      WithSendEthernet,
+	"--send-ip":       WithSendIP,
+	"--privileged":    WithPrivileged,
+	"--unprivileged":  WithUnprivileged,
+	"--unique":        WithUnique,
+}
+
+// commandValueFlags associates the nmap flags which take a value with their options.
+var commandValueFlags = map[string]func(value string) (Option, error){
+	"-p":              func(value string) (Option, error) { return WithPorts(value), nil },
+	"--exclude-ports": func(value string) (Option, error) { return WithPortExclusions(value), nil },
+	"--top-ports":     intOption(WithMostCommonPorts),
+	"-iL":             func(value string) (Option, error) { return WithTargetInput(value), nil },
+	"-iR":             intOption(WithRandomTargets),
+	"--exclude":       func(value string) (Option, error) { return WithTargetExclusions(value), nil },
+	"--excludefile":   func(value string) (Option, error) { return WithTargetExclusionInput(value), nil },
+	"--dns-servers":   func(value string) (Option, error) { return WithCustomDNSServers(value), nil },
+	"--script":        func(value string) (Option, error) { return WithScripts(value), nil },
+	"--script-args-file": func(value string) (Option, error) {
+		return WithScriptArgumentsFile(value), nil
+	},
+	"--script-timeout":      durationOption(WithScriptTimeout),
+	"--min-hostgroup":       intOption(WithMinHostgroup),
+	"--max-hostgroup":       intOption(WithMaxHostgroup),
+	"--min-parallelism":     intOption(WithMinParallelism),
+	"--max-parallelism":     intOption(WithMaxParallelism),
+	"--min-rtt-timeout":     durationOption(WithMinRTTTimeout),
+	"--max-rtt-timeout":     durationOption(WithMaxRTTTimeout),
+	"--initial-rtt-timeout": durationOption(WithInitialRTTTimeout),
+	"--max-retries":         intOption(WithMaxRetries),
+	"--host-timeout":        durationOption(WithHostTimeout),
+	"--scan-delay":          durationOption(WithScanDelay),
+	"--max-scan-delay":      durationOption(WithMaxScanDelay),
+	"--min-rate":            floatOption(WithMinRateFloat),
+	"--max-rate":            floatOption(WithMaxRateFloat),
+	"--stats-every":         func(value string) (Option, error) { return WithStatsEvery(value), nil },
+	"-e":                    func(value string) (Option, error) { return WithInterface(value), nil },
+	"-S":                    func(value string) (Option, error) { return WithSpoofIPAddress(value), nil },
+	"-D":                    func(value string) (Option, error) { return WithDecoys(value), nil },
+	"--proxies":             func(value string) (Option, error) { return WithProxies(value), nil },
+	"--spoof-mac":           func(value string) (Option, error) { return WithSpoofMAC(value), nil },
+	"--data-length":         intOption(WithDataLength),
+	"--datadir":             func(value string) (Option, error) { return WithDataDir(value), nil },
+	"--resume":              func(value string) (Option, error) { return WithResumePreviousScan(value), nil },
+	"--stylesheet":          func(value string) (Option, error) { return WithStylesheet(value), nil },
+	"-oN":                   func(value string) (Option, error) { return WithNmapOutput(value), nil },
+	"-oG":                   func(value string) (Option, error) { return WithGrepOutput(value), nil },
+	"-oS":                   func(value string) (Option, error) { return WithScriptKiddieOutput(value), nil },
+}
+
+// commandCustomValueFlags are the nmap flags which take a value but have no
+// matching option, and are kept as custom arguments along with their value.
+var commandCustomValueFlags = map[string]bool{
+	"-g":                  true,
+	"--source-port":       true,
+	"--mtu":               true,
+	"--data":              true,
+	"--data-string":       true,
+	"--ip-options":        true,
+	"--ttl":               true,
+	"--script-args":       true,
+	"--port-ratio":        true,
+	"--version-intensity": true,
+	"--max-os-tries":      true,
+	"--scanflags":         true,
+	"-sI":                 true,
+	"-b":                  true,
+	"--servicedb":         true,
+	"--versiondb":         true,
+	"-oM":                 true,
+}
+
+// ParseCommand parses a raw nmap command, such as "nmap -sV -p 22,80 scanme.nmap.org",
+// and returns the equivalent options, to help migrating existing scripts. The command
+// may start with the nmap binary, optionally prefixed with sudo, and its arguments can
+// be quoted like in a shell.
+// Flags without a matching option are kept using WithCustomArguments, and positional
+// arguments are targets. The XML output flags -oX and -oA are rejected, since the XML
+// output is handled by the scanner. Use Scanner.ToFile to keep it in a file instead.
+func ParseCommand(cmdline string) ([]Option, error) {
+	args, err := splitCommand(cmdline)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) > 0 && filepath.Base(args[0]) == "sudo" {
+		args = args[1:]
+	}
+	if len(args) > 0 && isNmapBinary(args[0]) {
+		args = args[1:]
+	}
+
+	var options []Option
+	var targets []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			targets = append(targets, args[i+1:]...)
+			break
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			targets = append(targets, arg)
+			continue
+		}
+
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !strings.HasPrefix(flag, "--") {
+			flag, value, hasValue = arg, "", false
+		}
+
+		if newOption, ok := commandFlags[flag]; ok && !hasValue {
+			options = append(options, newOption())
+			continue
+		}
+
+		if flag == "-oX" || flag == "-oA" {
+			return nil, fmt.Errorf("%w: %s is handled by the scanner, use Scanner.ToFile instead", ErrInvalidCommand, flag)
+		}
+
+		if timing, ok := strings.CutPrefix(flag, "-T"); ok && len(timing) == 1 && timing[0] >= '0' && timing[0] <= '9' {
+			options = append(options, WithTimingTemplate(Timing(timing[0]-'0')))
+			continue
+		}
+
+		newOption, isValueFlag := commandValueFlags[flag]
+		if !isValueFlag && !commandCustomValueFlags[flag] {
+			options = append(options, WithCustomArguments(arg))
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%w: missing value for %s", ErrInvalidCommand, flag)
+			}
+			i++
+			value = args[i]
+		}
+
+		if !isValueFlag {
+			options = append(options, WithCustomArguments(flag, value))
+			continue
+		}
+
+		option, err := newOption(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid value for %s: %w", ErrInvalidCommand, flag, err)
+		}
+		options = append(options, option)
+	}
+
+	if len(targets) > 0 {
+		options = append(options, WithTargets(targets...))
+	}
+
+	return options, nil
+}
+
+// isNmapBinary returns whether the given command argument is the nmap binary.
+func isNmapBinary(arg string) bool {
+	name := strings.ToLower(filepath.Base(arg))
+	return name == "nmap" || name == "nmap.exe"
+}
+
+// splitCommand splits a command line into arguments the way a shell would,
+// supporting single quotes, double quotes and backslash escapes.
+func splitCommand(cmdline string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var inArg bool
+	var quote rune
+
+	runes := []rune(cmdline)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrInvalidCommand)
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidCommand)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// intOption returns a parser for options taking an integer value.
+func intOption(newOption func(int) Option) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, err
+		}
+		return newOption(number), nil
+	}
+}
+
+// floatOption returns a parser for options taking a float value.
+func floatOption(newOption func(float64) Option) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return newOption(number), nil
+	}
+}
+
+// durationOption returns a parser for options taking a duration in nmap's format.
+func durationOption(newOption func(time.Duration) Option) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		duration, err := parseNmapDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		return newOption(duration), nil
+	}
+}
@@ -0,0 +1,129 @@
+package nmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		description string
+
+		cmdline string
+
+		expectedArgs []string
+		expectedErr  error
+	}{
+		{
+			description: "known flags and targets",
+
+			cmdline: "nmap -sS -sV -p 22,80 -T4 --max-retries 2 --host-timeout 30s scanme.nmap.org 192.168.0.0/24",
+
+			expectedArgs: []string{
+				"-sS",
+				"-sV",
+				"-p",
+				"22,80",
+				"-T4",
+				"--max-retries",
+				"2",
+				"--host-timeout",
+				"30000ms",
+				"--",
+				"scanme.nmap.org",
+				"192.168.0.0/24",
+			},
+		},
+		{
+			description: "mixed known and unknown flags",
+
+			cmdline: `sudo /usr/bin/nmap -vv --script=http-title,ssl-cert --script-args 'http.useragent="Mozilla 5.0"' ` +
+				`--ttl 64 --defeat-rst-ratelimit -Pn --min-rate=0.5 10.0.0.1`,
+
+			expectedArgs: []string{
+				"-vv",
+				"--script=http-title,ssl-cert",
+				"--script-args",
+				`http.useragent="Mozilla 5.0"`,
+				"--ttl",
+				"64",
+				"--defeat-rst-ratelimit",
+				"-Pn",
+				"--min-rate",
+				"0.5",
+				"--",
+				"10.0.0.1",
+			},
+		},
+		{
+			description: "without binary and with separator",
+
+			cmdline: "-F --exclude 10.0.0.2 -- 10.0.0.0/30",
+
+			expectedArgs: []string{
+				"-F",
+				"--exclude",
+				"10.0.0.2",
+				"--",
+				"10.0.0.0/30",
+			},
+		},
+		{
+			description: "XML output",
+
+			cmdline: "nmap -oX scan.xml localhost",
+
+			expectedErr: ErrInvalidCommand,
+		},
+		{
+			description: "missing flag value",
+
+			cmdline: "nmap localhost -p",
+
+			expectedErr: ErrInvalidCommand,
+		},
+		{
+			description: "invalid flag value",
+
+			cmdline: "nmap --max-retries lots localhost",
+
+			expectedErr: ErrInvalidCommand,
+		},
+		{
+			description: "unterminated quote",
+
+			cmdline: `nmap --script-args "user=admin localhost`,
+
+			expectedErr: ErrInvalidCommand,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			options, err := ParseCommand(test.cmdline)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err)
+			}
+
+			assert.Equal(t, test.expectedArgs, s.Args())
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	args, err := splitCommand(`nmap  -p "22, 80" --script-args 'a="b c"' escaped\ space "" -sV`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nmap", "-p", "22, 80", "--script-args", `a="b c"`, "escaped space", "", "-sV"}, args)
+}
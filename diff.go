@@ -0,0 +1,123 @@
+package nmap
+
+import "fmt"
+
+// RunDiff contains the changes between two runs, such as two scans of the
+// same network made a day apart. Hosts are matched by address, and ports by
+// protocol and number.
+type RunDiff struct {
+	// NewHosts are the hosts which are up in the new run, but not in the old one.
+	NewHosts []Host `json:"new_hosts"`
+	// RemovedHosts are the hosts which were up in the old run, but not in the new one.
+	RemovedHosts []Host `json:"removed_hosts"`
+	// OpenedPorts are the ports which are open in the new run, but were not in the
+	// old one, of the hosts which are up in both runs.
+	OpenedPorts []PortChange `json:"opened_ports"`
+	// ClosedPorts are the ports which were open in the old run, but are not in the
+	// new one, of the hosts which are up in both runs. Ports which are not reported
+	// anymore, or are reported as filtered, are considered closed.
+	ClosedPorts []PortChange `json:"closed_ports"`
+}
+
+// PortChange is a port whose state changed between two runs.
+type PortChange struct {
+	// Host is the address of the host of the port.
+	Host string `json:"host"`
+	// Port is the port as reported by the run in which it is open.
+	Port Port `json:"port"`
+}
+
+// IsEmpty returns whether the runs have the same hosts up and the same open ports.
+func (d RunDiff) IsEmpty() bool {
+	return len(d.NewHosts) == 0 && len(d.RemovedHosts) == 0 && len(d.OpenedPorts) == 0 && len(d.ClosedPorts) == 0
+}
+
+// Diff compares the hosts which are up and their open ports between an old and
+// a new run, for example to detect changes on a network which is scanned
+// periodically. The changes are listed in the order of the runs they come from.
+// A nil run is considered empty.
+func Diff(oldRun, newRun *Run) RunDiff {
+	if oldRun == nil {
+		oldRun = &Run{}
+	}
+	if newRun == nil {
+		newRun = &Run{}
+	}
+
+	oldHosts := hostsByAddress(oldRun.UpHosts())
+	newHosts := hostsByAddress(newRun.UpHosts())
+
+	var diff RunDiff
+	for _, host := range newRun.UpHosts() {
+		address := host.diffAddress()
+		oldHost, found := oldHosts[address]
+		if !found {
+			diff.NewHosts = append(diff.NewHosts, host)
+			continue
+		}
+
+		diff.OpenedPorts = append(diff.OpenedPorts, openedPorts(address, oldHost, host)...)
+	}
+
+	for _, host := range oldRun.UpHosts() {
+		address := host.diffAddress()
+		newHost, found := newHosts[address]
+		if !found {
+			diff.RemovedHosts = append(diff.RemovedHosts, host)
+			continue
+		}
+
+		diff.ClosedPorts = append(diff.ClosedPorts, openedPorts(address, newHost, host)...)
+	}
+
+	return diff
+}
+
+// openedPorts returns the ports which are open on the given host, but were
+// not on the previous version of the host.
+func openedPorts(address string, previous, host Host) []PortChange {
+	wasOpen := make(map[string]bool)
+	for _, port := range previous.Ports {
+		if port.Status() == Open {
+			wasOpen[portKey(port)] = true
+		}
+	}
+
+	var changes []PortChange
+	for _, port := range host.Ports {
+		if port.Status() == Open && !wasOpen[portKey(port)] {
+			changes = append(changes, PortChange{Host: address, Port: port})
+		}
+	}
+
+	return changes
+}
+
+// hostsByAddress indexes the given hosts by address.
+func hostsByAddress(hosts []Host) map[string]Host {
+	indexed := make(map[string]Host, len(hosts))
+	for _, host := range hosts {
+		indexed[host.diffAddress()] = host
+	}
+
+	return indexed
+}
+
+// diffAddress returns the address used to match the host across runs, which
+// is its first IP address, or its MAC address if it has none.
+func (h Host) diffAddress() string {
+	if ip := h.primaryIP(); ip != nil {
+		return ip.String()
+	}
+
+	if len(h.Addresses) > 0 {
+		return h.Addresses[0].Addr
+	}
+
+	return ""
+}
+
+// portKey returns the key used to match the port across runs.
+func portKey(port Port) string {
+	return string(port.Proto()) + "/" + fmt.Sprint(port.ID)
+}
@@ -0,0 +1,70 @@
+package nmap
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	parseFile := func(path string) *Run {
+		rawXML, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result Run
+		if err := Parse(rawXML, &result); err != nil {
+			t.Fatal(err)
+		}
+		return &result
+	}
+
+	oldRun := parseFile("tests/xml/scan_diff_old.xml")
+	newRun := parseFile("tests/xml/scan_diff_new.xml")
+
+	addresses := func(hosts []Host) []string {
+		var addrs []string
+		for _, host := range hosts {
+			addrs = append(addrs, host.Addresses[0].Addr)
+		}
+		return addrs
+	}
+	ports := func(changes []PortChange) []string {
+		var keys []string
+		for _, change := range changes {
+			keys = append(keys, fmt.Sprintf("%s %d/%s %s", change.Host, change.Port.ID, change.Port.Protocol, change.Port.State.State))
+		}
+		return keys
+	}
+
+	diff := Diff(oldRun, newRun)
+	assert.False(t, diff.IsEmpty())
+	assert.Equal(t, []string{"10.0.0.3"}, addresses(diff.NewHosts))
+	assert.Equal(t, []string{"10.0.0.2"}, addresses(diff.RemovedHosts))
+	assert.Equal(t, []string{"10.0.0.1 443/tcp open", "10.0.0.1 53/udp open"}, ports(diff.OpenedPorts))
+	assert.Equal(t, []string{"10.0.0.1 80/tcp open"}, ports(diff.ClosedPorts))
+
+	// Comparing the other way around swaps the changes.
+	reverse := Diff(newRun, oldRun)
+	assert.Equal(t, []string{"10.0.0.2"}, addresses(reverse.NewHosts))
+	assert.Equal(t, []string{"10.0.0.3"}, addresses(reverse.RemovedHosts))
+	assert.Equal(t, []string{"10.0.0.1 80/tcp open"}, ports(reverse.OpenedPorts))
+	assert.Equal(t, []string{"10.0.0.1 443/tcp open", "10.0.0.1 53/udp open"}, ports(reverse.ClosedPorts))
+
+	assert.True(t, Diff(newRun, newRun).IsEmpty())
+}
+
+func TestDiffNilRuns(t *testing.T) {
+	run := &Run{Hosts: []Host{{
+		Status:    Status{State: "up"},
+		Addresses: []Address{{Addr: "10.0.0.1", AddrType: "ipv4"}},
+		Ports:     []Port{{ID: 22, Protocol: "tcp", State: State{State: "open"}}},
+	}}}
+
+	assert.Len(t, Diff(nil, run).NewHosts, 1)
+	assert.Len(t, Diff(run, nil).RemovedHosts, 1)
+	assert.True(t, Diff(nil, nil).IsEmpty())
+}
@@ -2,6 +2,9 @@ package nmap
 
 import (
 	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
 )
 
 var (
@@ -13,12 +16,150 @@ var (
 	// ErrScanTimeout means that the provided context was done before the scanner finished its scan.
 	ErrScanTimeout = errors.New("nmap scan timed out")
 
+	// ErrScanInterrupt means that nmap was interrupted before finishing its scan, for example
+	// by a SIGINT from a user or a SIGTERM from an orchestrator shutting the scan down gracefully.
+	ErrScanInterrupt = errors.New("nmap scan interrupted")
+
 	// ErrMallocFailed means that nmap crashed due to insufficient memory, which may happen on large target networks.
 	ErrMallocFailed = errors.New("malloc failed, probably out of space")
 
 	// ErrParseOutput means that nmap's output was not parsed successfully.
 	ErrParseOutput = errors.New("unable to parse nmap output, see warnings for details")
 
+	// ErrNoOutput means that nmap did not write its XML output into the file
+	// given to ToFile, for example because it was not allowed to write it.
+	ErrNoOutput = errors.New("nmap did not produce any output")
+
+	// ErrInvalidSummary means that the summary of a run does not match the sentence that nmap writes
+	// at the end of a scan, for example because it is missing from the output.
+	ErrInvalidSummary = errors.New("unable to parse nmap summary")
+
+	// ErrInvalidCPE means that a CPE is neither a valid CPE 2.2 URI such as cpe:/a:openbsd:openssh:8.9,
+	// nor a valid CPE 2.3 formatted string such as cpe:2.3:a:openbsd:openssh:8.9:*:*:*:*:*:*:*.
+	ErrInvalidCPE = errors.New("invalid CPE")
+
 	// ErrResolveName means that Nmap could not resolve a name.
 	ErrResolveName = errors.New("nmap could not resolve a name")
+
+	// ErrConflictingOptions means that the scanner was given options that can't be used together.
+	ErrConflictingOptions = errors.New("conflicting nmap options")
+
+	// ErrInvalidTarget means that a target given to the scanner is not valid.
+	ErrInvalidTarget = errors.New("invalid nmap target")
+
+	// ErrInterfaceRequired means that the scan requires an interface to be specified using WithInterface.
+	ErrInterfaceRequired = errors.New("nmap requires an interface for this scan")
+
+	// ErrInterfaceNotFound means that nmap could not open the network interface it was given,
+	// usually because the device given to WithInterface does not exist, which is common in containers.
+	ErrInterfaceNotFound = errors.New("nmap could not open the network interface")
+
+	// ErrInvalidOption means that a value given to one of the scanner's options is not valid.
+	ErrInvalidOption = errors.New("invalid nmap option")
+
+	// ErrPcapMissing means that nmap could not find a working packet capture library, which happens on Windows
+	// when Npcap is not installed. Installing the latest version of Npcap from https://npcap.com usually fixes it.
+	ErrPcapMissing = errors.New("nmap requires Npcap, install it from https://npcap.com")
 )
+
+// fatalWarnings associates the substrings of nmap warnings that indicate a
+// failed scan with the matching sentinel errors. Failing to resolve one of the
+// targets isn't fatal, since nmap keeps scanning the other ones. Neither are the
+// warnings which only mention the packet capture library, such as the one printed
+// when nmap falls back to connect() scans without it.
+var fatalWarnings = []struct {
+	substring string
+	err       error
+}{
+	{substring: "Malloc Failed!", err: ErrMallocFailed},
+	{substring: "Error resolving name", err: ErrResolveName},
+	{substring: "Nmap requires Npcap", err: ErrPcapMissing},
+	{substring: "Call to pcap_open_live() failed", err: ErrPcapMissing},
+	{substring: "Failed to open device", err: ErrInterfaceNotFound},
+}
+
+// interruptExitCodes are the exit codes of processes stopped by an interrupt: 130 and 143
+// are the shell conventions for SIGINT and SIGTERM, and 0xC000013A is the exit code of
+// Windows processes stopped by ctrl+c or by closing their console.
+var interruptExitCodes = []int{130, 143, 0xC000013A}
+
+// isInterruptExit returns whether the given error is the exit error of a process
+// which was interrupted.
+func isInterruptExit(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+
+	// Processes killed by a signal have no exit code on Unix.
+	if isInterruptSignal(exitErr) {
+		return true
+	}
+
+	// Exit codes are unsigned on Windows, but may be reported as negative numbers.
+	code := int(uint32(exitErr.ExitCode()))
+	for _, interruptCode := range interruptExitCodes {
+		if code == interruptCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// warningError returns an error wrapping the sentinel error matching the
+// given warning, or nil if the warning isn't fatal.
+func warningError(warning string) error {
+	for _, fatal := range fatalWarnings {
+		if strings.Contains(warning, fatal.substring) {
+			return fmt.Errorf("%w: %s", fatal.err, warning)
+		}
+	}
+
+	return nil
+}
+
+// scanErrors associates the substrings of the error messages that nmap reports
+// in its XML output with the matching sentinel errors.
+var scanErrors = []struct {
+	substring string
+	err       error
+}{
+	{substring: "Error resolving name", err: ErrResolveName},
+	{substring: "Failed to resolve", err: ErrResolveName},
+	{substring: "No targets", err: ErrInvalidTarget},
+}
+
+// ErrScanError is returned when nmap reports in its XML output that the scan
+// failed. It contains nmap's error message, and wraps the sentinel error
+// matching the message if there is one, so that it can be checked using errors.Is.
+type ErrScanError struct {
+	Message string
+	Err     error
+}
+
+func (e *ErrScanError) Error() string {
+	if e.Message == "" {
+		return "nmap scan failed"
+	}
+
+	return fmt.Sprintf("nmap scan failed: %s", e.Message)
+}
+
+// Unwrap returns the sentinel error matching nmap's error message, if any.
+func (e *ErrScanError) Unwrap() error {
+	return e.Err
+}
+
+// newErrScanError creates an ErrScanError for the given nmap error message.
+func newErrScanError(message string) *ErrScanError {
+	scanErr := &ErrScanError{Message: message}
+	for _, known := range scanErrors {
+		if strings.Contains(message, known.substring) {
+			scanErr.Err = known.err
+			break
+		}
+	}
+
+	return scanErr
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package nmap
+
+import "os/exec"
+
+// isInterruptSignal returns whether the process of the given exit error was
+// killed by an interrupt signal, which only exists on Unix. Elsewhere, such as
+// on Windows, interrupted processes are recognized by their exit code.
+func isInterruptSignal(exitErr *exec.ExitError) bool {
+	return false
+}
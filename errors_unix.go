@@ -0,0 +1,19 @@
+//go:build unix
+
+package nmap
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// isInterruptSignal returns whether the process of the given exit error was
+// killed by SIGINT or SIGTERM.
+func isInterruptSignal(exitErr *exec.ExitError) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return false
+	}
+
+	return status.Signal() == syscall.SIGINT || status.Signal() == syscall.SIGTERM
+}
@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -26,16 +31,51 @@ type Scanner struct {
 	modifySysProcAttr func(*syscall.SysProcAttr)
 
 	args       []string
+	targets    []string
 	binaryPath string
 	ctx        context.Context
+	timeout    time.Duration
 
 	portFilter func(Port) bool
 	hostFilter func(Host) bool
 
+	scriptFilter func(Script) bool
+
+	withoutDownHosts bool
+
+	statsCallback func(Stats)
+	hostCallbacks []func(Host)
+
+	beforeRun []func(args []string)
+	afterRun  []func(result *Run, err error)
+
+	suppressedWarnings []string
+	unknownProfiles    []string
+	strictTargets      bool
+	scriptArgFiles     []scriptArgFile
+
+	maxHosts int
+
+	shuffleSeed *int64
+
+	randomScanDelay *randomDelay
+	random          func(n int64) int64
+
+	durationUnits DurationUnits
+
 	doneAsync    chan error
 	liveProgress chan float32
+	liveETA      chan time.Time
 	streamer     io.Writer
+	stdin        io.Reader
 	toFile       *string
+
+	outputFileMode *os.FileMode
+
+	unmanagedOutput bool
+	lowMemoryParse  bool
+
+	scanID string
 }
 
 // Option is a function that is used for grouping of Scanner options.
@@ -55,6 +95,10 @@ func NewScanner(ctx context.Context, options ...Option) (*Scanner, error) {
 		option(scanner)
 	}
 
+	if err := scanner.validate(); err != nil {
+		return nil, err
+	}
+
 	if scanner.binaryPath == "" {
 		var err error
 		scanner.binaryPath, err = exec.LookPath("nmap")
@@ -68,18 +112,39 @@ func NewScanner(ctx context.Context, options ...Option) (*Scanner, error) {
 
 // Async will run the nmap scan asynchronously. You need to provide a channel with error type.
 // When the scan is finished an error or nil will be piped through this channel.
+// Like with a synchronous scan, the warnings are available once the error was received,
+// both through the warnings returned by Run and through the Warnings method of the result,
+// including when the scan failed.
 func (s *Scanner) Async(doneAsync chan error) *Scanner {
 	s.doneAsync = doneAsync
 	return s
 }
 
-// Progress pipes the progress of nmap every 100ms. It needs a channel of type float.
+// Progress pipes the progress of nmap every 100ms. It needs a channel of type float,
+// which is closed once the scan is finished or its context is cancelled. Since the
+// last update may be sent once the scan is finished, the channel should be read until
+// it is closed, unless the scanner's context is cancelled.
+// When the XML output is written to a file using ToFile, the progress is read from
+// the timing lines of nmap's normal output instead.
 func (s *Scanner) Progress(liveProgress chan float32) *Scanner {
 	s.args = append(s.args, "--stats-every", "100ms")
 	s.liveProgress = liveProgress
 	return s
 }
 
+// ETA pipes the estimated completion time of nmap's current task whenever it changes.
+// It needs a channel of type time.Time, which is closed once the scan is finished or
+// its context is cancelled, and should be read until then, like the one given to Progress.
+// When the XML output is written to a file using ToFile, the estimation is computed from
+// the remaining time reported by the timing lines of nmap's normal output instead.
+func (s *Scanner) ETA(liveETA chan time.Time) *Scanner {
+	if !s.hasFlag("--stats-every") {
+		s.args = append(s.args, "--stats-every", "100ms")
+	}
+	s.liveETA = liveETA
+	return s
+}
+
 // ToFile enables the Scanner to write the nmap XML output to a given path.
 // Nmap will write the normal CLI output to stdout. The XML is parsed from file after the scan is finished.
 func (s *Scanner) ToFile(file string) *Scanner {
@@ -99,50 +164,109 @@ func (s *Scanner) Streamer(stream io.Writer) *Scanner {
 // You need to create a Run struct and warnings array first so the function can parse it.
 func (s *Scanner) Run() (result *Run, warnings *[]string, err error) {
 	var stdoutPipe io.ReadCloser
-	var stdout bytes.Buffer
+	var stdout syncBuffer
 	var stderr bytes.Buffer
 
 	warnings = &[]string{} // Instantiate warnings array
 
-	args := s.args
+	// Options added using AddOptions are only validated here.
+	if err = s.validate(); err != nil {
+		s.runAfterHooks(result, err)
+		return result, warnings, err
+	}
 
-	// Write XML to standard output.
-	// If toFile is set then write XML to file.
-	if s.toFile != nil {
-		args = append(args, "-oX", *s.toFile)
-	} else {
-		args = append(args, "-oX", "-")
+	// The output file is only known once ToFile was called.
+	if s.outputFileMode != nil && s.toFile == nil {
+		err = fmt.Errorf("%w: WithOutputFileMode requires the XML output to be written using ToFile", ErrInvalidOption)
+		s.runAfterHooks(result, err)
+		return result, warnings, err
+	}
+
+	args := s.buildArgs()
+	for _, hook := range s.beforeRun {
+		hook(append([]string{}, args...))
+	}
+
+	// Derive the scan deadline from the scanner's context if a timeout is set.
+	ctx, cancel := s.ctx, context.CancelFunc(func() {})
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(s.ctx, s.timeout)
 	}
 
 	// Prepare nmap process.
-	cmd := exec.CommandContext(s.ctx, s.binaryPath, args...)
+	cmd := exec.CommandContext(ctx, s.binaryPath, args...)
 	if s.modifySysProcAttr != nil {
 		s.modifySysProcAttr(cmd.SysProcAttr)
 	}
 	stdoutPipe, err = cmd.StdoutPipe()
 	if err != nil {
+		cancel()
+		s.runAfterHooks(result, err)
 		return result, warnings, err
 	}
-	stdoutDuplicate := io.TeeReader(stdoutPipe, &stdout)
+	// In low memory mode, the XML output is decoded as it is read instead of being kept.
+	var decoded *runDecoder
+	var stdoutCopy io.Writer = &stdout
+	if s.lowMemoryParse && s.xmlOnStdout() {
+		decoded = newRunDecoder(s.maxHosts)
+		stdoutCopy = decoded
+	}
+	stdoutDuplicate := io.TeeReader(stdoutPipe, stdoutCopy)
 	cmd.Stderr = &stderr
+	cmd.Stdin = s.stdin
 
 	// According to cmd.StdoutPipe() doc, we must not "call Wait before all reads from the pipe have completed"
 	// We use this WaitGroup to wait for all IO operations to finish before calling wait
 	var wg sync.WaitGroup
 
+	// Decode hosts as they are written if any callback needs them.
+	var hosts *hostDecoder
+	if len(s.hostCallbacks) > 0 {
+		var decoded int
+		hosts = newHostDecoder(func(host Host) {
+			// Hosts past the limit are left out of the result, so they are not reported either.
+			if s.maxHosts > 0 && decoded >= s.maxHosts {
+				return
+			}
+			decoded++
+
+			for _, callback := range s.hostCallbacks {
+				callback(host)
+			}
+		})
+	}
+
 	var streamerErrs *errgroup.Group
 	if s.streamer != nil {
+		streamer := s.streamer
+		if hosts != nil {
+			streamer = io.MultiWriter(s.streamer, hosts)
+		}
+
 		streamerErrs, _ = errgroup.WithContext(s.ctx)
 		wg.Add(1)
 		streamerErrs.Go(func() error {
 			defer wg.Done()
-			_, err = io.Copy(s.streamer, stdoutDuplicate)
+			_, err := io.Copy(streamer, stdoutDuplicate)
+			if err != nil {
+				// Keep reading the output if the streamer fails, otherwise
+				// nmap blocks on writing to its stdout and never exits.
+				io.Copy(io.Discard, stdoutDuplicate)
+			}
+			if hosts != nil {
+				hosts.Close()
+			}
 			return err
 		})
 	} else {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			if hosts != nil {
+				io.Copy(hosts, stdoutDuplicate)
+				hosts.Close()
+				return
+			}
 			io.Copy(io.Discard, stdoutDuplicate)
 		}()
 	}
@@ -150,6 +274,8 @@ func (s *Scanner) Run() (result *Run, warnings *[]string, err error) {
 	// Run nmap process.
 	err = cmd.Start()
 	if err != nil {
+		cancel()
+		s.runAfterHooks(result, err)
 		return result, warnings, err
 	}
 
@@ -158,36 +284,69 @@ func (s *Scanner) Run() (result *Run, warnings *[]string, err error) {
 	doneProgress := make(chan bool, 1)
 
 	go func() {
+		defer cancel()
+
 		wg.Wait()
+		if decoded != nil {
+			decoded.Close()
+		}
 		err := cmd.Wait()
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = ErrScanTimeout
+		} else if isInterruptExit(err) {
+			err = fmt.Errorf("%w: %w", ErrScanInterrupt, err)
+		}
 		if streamerErrs != nil {
 			streamerError := streamerErrs.Wait()
 			if streamerError != nil {
-				*warnings = append(*warnings, fmt.Sprintf("read from stdout failed: %s", err))
+				*warnings = append(*warnings, fmt.Sprintf("read from stdout failed: %s", streamerError))
 			}
 		}
 		done <- err
 	}()
 
-	// Make goroutine to check the progress every second.
-	// Listening for channel doneProgress.
-	if s.liveProgress != nil {
+	// Make goroutine to check the progress every 100ms.
+	// A pending update is still sent once the scan is over, so the goroutine
+	// only returns once it is read, or once the scanner's context is cancelled.
+	if s.liveProgress != nil || s.liveETA != nil {
 		go func() {
-			type progress struct {
-				TaskProgress []TaskProgress `xml:"taskprogress" json:"task_progress"`
-			}
+			defer func() {
+				if s.liveProgress != nil {
+					close(s.liveProgress)
+				}
+				if s.liveETA != nil {
+					close(s.liveETA)
+				}
+			}()
+
+			var lastETA time.Time
 			for {
 				select {
 				case <-doneProgress:
-					close(s.liveProgress)
 					return
-				default:
-					time.Sleep(time.Millisecond * 100)
-					var p progress
-					_ = xml.Unmarshal(stdout.Bytes(), &p)
-					progressIndex := len(p.TaskProgress) - 1
-					if progressIndex >= 0 {
-						s.liveProgress <- p.TaskProgress[progressIndex].Percent
+				case <-s.ctx.Done():
+					return
+				case <-time.After(time.Millisecond * 100):
+				}
+
+				output := stdout.Bytes()
+				if s.liveProgress != nil {
+					if percent, ok := s.latestProgress(output); ok {
+						select {
+						case s.liveProgress <- clampProgress(percent):
+						case <-s.ctx.Done():
+							return
+						}
+					}
+				}
+				if s.liveETA != nil {
+					if eta, ok := s.latestETA(output); ok && !eta.Equal(lastETA) {
+						lastETA = eta
+						select {
+						case s.liveETA <- eta:
+						case <-s.ctx.Done():
+							return
+						}
 					}
 				}
 			}
@@ -200,16 +359,127 @@ func (s *Scanner) Run() (result *Run, warnings *[]string, err error) {
 	result = &Run{}
 	if s.doneAsync != nil {
 		go func() {
-			s.doneAsync <- s.processNmapResult(result, warnings, &stdout, &stderr, done, doneProgress)
+			err := s.processNmapResult(result, warnings, &stdout, &stderr, decoded, done, doneProgress)
+			s.runAfterHooks(result, err)
+			s.doneAsync <- err
 		}()
 	} else {
-		err = s.processNmapResult(result, warnings, &stdout, &stderr, done, doneProgress)
+		err = s.processNmapResult(result, warnings, &stdout, &stderr, decoded, done, doneProgress)
+		s.runAfterHooks(result, err)
 	}
 
 	return result, warnings, err
 }
 
+// syncBuffer is a bytes.Buffer which can be read while nmap's stdout is being written to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements the io.Writer interface.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes returns a copy of the content written so far.
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// runAfterHooks calls the hooks set using WithAfterRun.
+func (s *Scanner) runAfterHooks(result *Run, err error) {
+	for _, hook := range s.afterRun {
+		hook(result, err)
+	}
+}
+
+// progressRegex matches the progress of the current task in nmap's normal output, such as:
+// SYN Stealth Scan Timing: About 42.42% done; ETC: 18:31 (0:00:07 remaining)
+var progressRegex = regexp.MustCompile(`About (\d+(?:\.\d+)?)% done`)
+
+// latestProgress returns the progress of the latest task found in nmap's stdout. When the XML
+// output is written to a file, stdout contains the normal output, from which the progress is read instead.
+func (s *Scanner) latestProgress(stdout []byte) (float32, bool) {
+	if !s.xmlOnStdout() {
+		matches := progressRegex.FindAllSubmatch(stdout, -1)
+		if len(matches) == 0 {
+			return 0, false
+		}
+
+		percent, err := strconv.ParseFloat(string(matches[len(matches)-1][1]), 32)
+		if err != nil {
+			return 0, false
+		}
+		return float32(percent), true
+	}
+
+	var p struct {
+		TaskProgress []TaskProgress `xml:"taskprogress" json:"task_progress"`
+	}
+	_ = xml.Unmarshal(stdout, &p)
+	progressIndex := len(p.TaskProgress) - 1
+	if progressIndex < 0 {
+		return 0, false
+	}
+	return p.TaskProgress[progressIndex].Percent, true
+}
+
+// remainingRegex matches the remaining time of the current task in nmap's normal output, such as:
+// SYN Stealth Scan Timing: About 42.42% done; ETC: 18:31 (0:00:07 remaining)
+var remainingRegex = regexp.MustCompile(`\((\d+):(\d{2}):(\d{2}) remaining\)`)
+
+// latestETA returns the estimated completion time of the latest task found in nmap's stdout.
+// The normal output only contains the time of day of the estimation, so it is computed
+// from the remaining time instead.
+func (s *Scanner) latestETA(stdout []byte) (time.Time, bool) {
+	if !s.xmlOnStdout() {
+		matches := remainingRegex.FindAllSubmatch(stdout, -1)
+		if len(matches) == 0 {
+			return time.Time{}, false
+		}
+
+		latest := matches[len(matches)-1]
+		hours, _ := strconv.Atoi(string(latest[1]))
+		minutes, _ := strconv.Atoi(string(latest[2]))
+		seconds, _ := strconv.Atoi(string(latest[3]))
+		remaining := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+		return time.Now().Add(remaining).Truncate(time.Second), true
+	}
+
+	var p struct {
+		TaskProgress []TaskProgress `xml:"taskprogress" json:"task_progress"`
+	}
+	_ = xml.Unmarshal(stdout, &p)
+	progressIndex := len(p.TaskProgress) - 1
+	if progressIndex < 0 {
+		return time.Time{}, false
+	}
+
+	etc := time.Time(p.TaskProgress[progressIndex].Etc)
+	return etc, !etc.IsZero()
+}
+
+// clampProgress bounds a progress percentage to the [0,100] range, since the
+// values reported by nmap can slightly overshoot due to rounding.
+func clampProgress(percent float32) float32 {
+	switch {
+	case percent < 0:
+		return 0
+	case percent > 100:
+		return 100
+	default:
+		return percent
+	}
+}
+
 // AddOptions sets more scan options after the scan is created.
+// Since it can't return an error, the options are validated when
+// running the scan, which then returns the same errors as NewScanner.
 func (s *Scanner) AddOptions(options ...Option) *Scanner {
 	for _, option := range options {
 		option(s)
@@ -217,9 +487,70 @@ func (s *Scanner) AddOptions(options ...Option) *Scanner {
 	return s
 }
 
-// Args return the list of nmap args.
+// Args return the list of nmap args, followed by the targets.
 func (s *Scanner) Args() []string {
-	return s.args
+	args := make([]string, 0, len(s.args)+len(s.targets)+1)
+	args = append(args, s.args...)
+	s.formatDurations(args)
+	return s.appendTargets(args)
+}
+
+// buildArgs assembles the arguments given to the nmap binary when running
+// the scan. The output directive is added to the options, and the targets
+// are always placed last.
+func (s *Scanner) buildArgs() []string {
+	args := make([]string, 0, len(s.args)+len(s.targets)+5)
+	args = append(args, s.args...)
+
+	// Pick a new scan delay for every run.
+	if s.randomScanDelay != nil {
+		random := s.random
+		if random == nil {
+			random = rand.Int63n
+		}
+		delay := s.randomScanDelay.pick(random)
+		args = append(args, "--scan-delay", fmt.Sprintf("%dms", delay.Milliseconds()))
+	}
+	s.formatDurations(args)
+
+	// Write XML to standard output.
+	// If toFile is set then write XML to file, and if the output is unmanaged, leave it to the user's arguments.
+	switch {
+	case s.unmanagedOutput:
+	case s.toFile != nil:
+		args = append(args, "-oX", *s.toFile)
+	default:
+		args = append(args, "-oX", "-")
+	}
+
+	return s.appendTargets(args)
+}
+
+// xmlOnStdout returns whether nmap writes its XML output to stdout. Otherwise,
+// stdout contains nmap's normal output.
+func (s *Scanner) xmlOnStdout() bool {
+	return s.toFile == nil && !s.unmanagedOutput
+}
+
+// appendTargets appends the targets to the given arguments, after a "--"
+// separator which ends nmap's option parsing, so that targets can never
+// be interpreted as options.
+func (s *Scanner) appendTargets(args []string) []string {
+	if len(s.targets) == 0 {
+		return args
+	}
+
+	targets := s.targets
+	if s.shuffleSeed != nil {
+		targets = make([]string, len(s.targets))
+		copy(targets, s.targets)
+		rand.New(rand.NewSource(*s.shuffleSeed)).Shuffle(len(targets), func(i, j int) {
+			targets[i], targets[j] = targets[j], targets[i]
+		})
+	}
+
+	args = append(args, "--")
+	return append(args, targets...)
 }
 
 func chooseHosts(result *Run, filter func(Host) bool) {
@@ -248,50 +579,133 @@ func choosePorts(result *Run, filter func(Port) bool) {
 	}
 }
 
-func (s *Scanner) processNmapResult(result *Run, warnings *[]string, stdout, stderr *bytes.Buffer, done chan error, doneProgress chan bool) error {
+// isNotDown returns whether nmap did not report the host as down.
+func isNotDown(host Host) bool {
+	return host.Status.State != "down"
+}
+
+func chooseScripts(result *Run, filter func(Script) bool) {
+	filterScripts := func(scripts []Script) []Script {
+		var filteredScripts []Script
+
+		for _, script := range scripts {
+			if filter(script) {
+				filteredScripts = append(filteredScripts, script)
+			}
+		}
+
+		return filteredScripts
+	}
+
+	for idx := range result.Hosts {
+		result.Hosts[idx].HostScripts = filterScripts(result.Hosts[idx].HostScripts)
+		for portIdx := range result.Hosts[idx].Ports {
+			result.Hosts[idx].Ports[portIdx].Scripts = filterScripts(result.Hosts[idx].Ports[portIdx].Scripts)
+		}
+	}
+}
+
+func (s *Scanner) processNmapResult(result *Run, warnings *[]string, stdout *syncBuffer, stderr *bytes.Buffer, decoded *runDecoder, done chan error, doneProgress chan bool) error {
+	// Attach the warnings and the scan ID to the result once processing is over.
+	defer func() {
+		result.warnings = *warnings
+		result.scanID = s.scanID
+	}()
+
 	// Wait for nmap to finish.
 	var err = <-done
 	close(doneProgress)
+
+	// Set the mode of the output file as soon as nmap is done writing it.
+	if modeErr := s.setOutputFileMode(); err == nil {
+		err = modeErr
+	}
 	if err != nil {
+		// Nmap aborts on some fatal errors, which are explained on stderr.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if stderrErr := checkStdErr(stderr, warnings); stderrErr != nil {
+				return stderrErr
+			}
+		}
 		return err
 	}
 
+	// Keep the script trace apart from the warnings.
+	result.scriptTrace = parseScriptTrace(stderr)
+
+	// Nmap is done writing to stdout, so a single snapshot of it is enough.
+	output := stdout.Bytes()
+
+	// Nmap only prints normal output to stdout when the XML is written to a file.
+	result.sourceIface = parseSourceInterface(stderr.Bytes())
+	if result.sourceIface == "" && !s.xmlOnStdout() {
+		result.sourceIface = parseSourceInterface(output)
+	}
+
 	// Check stderr output.
-	if err := checkStdErr(stderr, warnings); err != nil {
+	err = checkStdErr(stderr, warnings)
+	*warnings = suppressWarnings(*warnings, s.suppressedWarnings)
+	if err != nil {
 		return err
 	}
 
+	// The output is handled by the user's own arguments.
+	if s.unmanagedOutput {
+		return nil
+	}
+
 	// Parse nmap xml output. Usually nmap always returns valid XML, even if there is a scan error.
 	// Potentially available warnings are returned too, but probably not the reason for a broken XML.
 	if s.toFile != nil {
-		err = result.FromFile(*s.toFile)
+		var content []byte
+		content, err = s.readOutputFile()
+		if err != nil {
+			return err
+		}
+
+		err = parse(content, result, s.maxHosts)
+	} else if decoded != nil {
+		*result, err = decoded.Result()
 	} else {
-		err = Parse(stdout.Bytes(), result)
+		err = parse(output, result, s.maxHosts)
 	}
 	if err != nil {
 		*warnings = append(*warnings, err.Error()) // Append parsing error to warnings for those who are interested.
-		return ErrParseOutput
+		return fmt.Errorf("%w: %w", ErrParseOutput, err)
+	}
+
+	// Deliver the run statistics as soon as they are available.
+	if s.statsCallback != nil {
+		s.statsCallback(result.Stats)
 	}
 
 	// Critical scan errors are reflected in the XML.
-	if result != nil && len(result.Stats.Finished.ErrorMsg) > 0 {
-		switch {
-		case strings.Contains(result.Stats.Finished.ErrorMsg, "Error resolving name"):
-			return ErrResolveName
-		default:
-			return fmt.Errorf(result.Stats.Finished.ErrorMsg)
-		}
+	if result.Stats.Finished.Exit == "error" || len(result.Stats.Finished.ErrorMsg) > 0 {
+		return newErrScanError(result.Stats.Finished.ErrorMsg)
 	}
 
 	// Call filters if they are set.
+	s.applyFilters(result)
+
+	return err
+}
+
+// applyFilters removes the scripts, ports and hosts of the result which don't
+// fulfill the filters of the scanner.
+func (s *Scanner) applyFilters(result *Run) {
+	if s.scriptFilter != nil {
+		chooseScripts(result, s.scriptFilter)
+	}
 	if s.portFilter != nil {
 		choosePorts(result, s.portFilter)
 	}
 	if s.hostFilter != nil {
 		chooseHosts(result, s.hostFilter)
 	}
-
-	return err
+	if s.withoutDownHosts {
+		chooseHosts(result, isNotDown)
+	}
 }
 
 // checkStdErr writes the output of stderr to the warnings array.
@@ -306,15 +720,115 @@ func checkStdErr(stderr *bytes.Buffer, warnings *[]string) error {
 	// Check for warnings that will inevitably lead to parsing errors, hence, have priority.
 	for _, warning := range stderrSplit {
 		warning = strings.Trim(warning, " ")
+		if isScriptTraceLine(warning) {
+			continue
+		}
 		*warnings = append(*warnings, warning)
-		switch {
-		case strings.Contains(warning, "Malloc Failed!"):
-			return ErrMallocFailed
+		if err := warningError(warning); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// setOutputFileMode sets the mode given to WithOutputFileMode on the file
+// given to ToFile, if nmap wrote it.
+func (s *Scanner) setOutputFileMode() error {
+	if s.outputFileMode == nil || s.toFile == nil {
+		return nil
+	}
+
+	if err := os.Chmod(*s.toFile, *s.outputFileMode); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to set the mode of %s: %w", *s.toFile, err)
+	}
+
+	return nil
+}
+
+// readOutputFile reads the XML written by nmap into the file given to ToFile.
+// When output is appended, only the latest run is returned. A missing or empty
+// file means that nmap could not write it, which is reported as ErrNoOutput
+// to distinguish it from parsing failures.
+func (s *Scanner) readOutputFile() ([]byte, error) {
+	content, err := os.ReadFile(*s.toFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to read %s: %w", ErrNoOutput, *s.toFile, err)
+	}
+
+	if s.hasArg("--append-output") {
+		content = latestRun(content)
+	}
+
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, fmt.Errorf("%w: %s is empty", ErrNoOutput, *s.toFile)
+	}
+
+	return content, nil
+}
+
+// suppressWarnings removes the warnings containing any of the given patterns,
+// except for the ones that indicate a failed scan.
+func suppressWarnings(warnings []string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return warnings
+	}
+
+	kept := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		suppressed := false
+		for _, pattern := range patterns {
+			if strings.Contains(warning, pattern) {
+				suppressed = warningError(warning) == nil
+				break
+			}
+		}
+
+		if !suppressed {
+			kept = append(kept, warning)
+		}
+	}
+
+	return kept
+}
+
+// scriptTraceRegex matches the lines printed by nmap when WithScriptTrace is used, such as:
+// NSE: TCP 192.168.1.2:51234 > 192.168.1.1:80 | CONNECT
+var scriptTraceRegex = regexp.MustCompile(`^NSE: (TCP|UDP|SSL) \S+ [<>] \S+ \|`)
+
+// isScriptTraceLine returns whether the given stderr line is part of the script trace.
+func isScriptTraceLine(line string) bool {
+	return scriptTraceRegex.MatchString(line)
+}
+
+// parseScriptTrace returns the script trace lines found in the stderr output.
+func parseScriptTrace(stderr *bytes.Buffer) []string {
+	var trace []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		line = strings.Trim(line, " ")
+		if isScriptTraceLine(line) {
+			trace = append(trace, line)
+		}
+	}
+
+	return trace
+}
+
+// sourceInterfaceRegex matches the line printed by nmap in debugging mode when
+// it starts capturing packets, which mentions the interface it uses, such as:
+// Packet capture filter (device eth0): dst host 192.168.1.2 and (icmp or ...)
+var sourceInterfaceRegex = regexp.MustCompile(`Packet capture filter \(device ([^)\s]+)\)`)
+
+// parseSourceInterface returns the interface used by nmap to send its probes,
+// if it can be found in the given output.
+func parseSourceInterface(output []byte) string {
+	match := sourceInterfaceRegex.FindSubmatch(output)
+	if match == nil {
+		return ""
+	}
+
+	return string(match[1])
+}
+
 // WithCustomArguments sets custom arguments to give to the nmap binary.
 // There should be no reason to use this, unless you are using a custom build
 // of nmap or that this repository isn't up to date with the latest options
@@ -329,6 +843,15 @@ func WithCustomArguments(args ...string) Option {
 	}
 }
 
+// WithScanID sets an identifier which is attached to the results of the scanner,
+// and available through Run.ScanID, to match results to the requests which
+// triggered them in pipelines running many scans. It isn't given to nmap.
+func WithScanID(id string) Option {
+	return func(s *Scanner) {
+		s.scanID = id
+	}
+}
+
 // WithBinaryPath sets the nmap binary path for a scanner.
 func WithBinaryPath(binaryPath string) Option {
 	return func(s *Scanner) {
@@ -355,3 +878,138 @@ func WithFilterHost(hostFilter func(Host) bool) Option {
 		s.hostFilter = hostFilter
 	}
 }
+
+// WithFilterScript allows to set a custom function to filter out the script
+// results of ports and hosts that don't fulfill a given condition. When the
+// given function returns true, the script result is kept, otherwise it is
+// removed from the result. Scripts are filtered before ports and hosts, so
+// that the filters set using WithFilterPort and WithFilterHost only see the
+// kept scripts.
+func WithFilterScript(scriptFilter func(Script) bool) Option {
+	return func(s *Scanner) {
+		s.scriptFilter = scriptFilter
+	}
+}
+
+// WithoutDownHosts removes the hosts that nmap reported as down from the result.
+// Nmap only reports down hosts when its verbosity is increased, such as when using
+// WithVerbosity, which bloats the results of large sweeps. Can be used along with
+// WithFilterHost.
+func WithoutDownHosts() Option {
+	return func(s *Scanner) {
+		s.withoutDownHosts = true
+	}
+}
+
+// WithUnmanagedOutput stops the scanner from adding its own XML output directive,
+// leaving the output entirely to the user's arguments, such as WithNmapOutput or
+// a custom -oX or -oA. Since the scanner then has no XML output to parse, Run returns an
+// empty result, and only reports errors from nmap's exit status and stderr.
+func WithUnmanagedOutput() Option {
+	return func(s *Scanner) {
+		s.unmanagedOutput = true
+	}
+}
+
+// WithMaxHosts limits the number of hosts kept in the result to the first n
+// hosts completed by nmap, to bound memory usage on scans which unexpectedly
+// match huge numbers of hosts. The hosts past the limit are never unmarshalled,
+// and are left out of the raw XML of the result. Along with WithLowMemoryParse,
+// they are never held in memory at all. The scan itself isn't stopped, and
+// Run.Truncated reports whether hosts were left out. The limit also applies to
+// the hosts given to callbacks such as WithScriptResultCallback. A limit of zero
+// keeps all hosts.
+func WithMaxHosts(n int) Option {
+	return func(s *Scanner) {
+		s.maxHosts = n
+	}
+}
+
+// WithOutputFileMode sets the permissions of the XML output file written using ToFile,
+// which otherwise depend on the umask of the process, for example to let another
+// user or process read it. The mode can only contain permission bits, and must let
+// the owner read the file, since it is parsed once the scan is finished. It is set
+// as soon as nmap exits. Running a scan without ToFile returns ErrInvalidOption.
+func WithOutputFileMode(mode os.FileMode) Option {
+	return func(s *Scanner) {
+		s.outputFileMode = &mode
+	}
+}
+
+// WithLowMemoryParse makes the scanner decode nmap's XML output as it is written instead
+// of keeping all of it in memory until the scan is finished, which keeps memory usage
+// flat on large scans. In exchange, the raw XML isn't available from the result, and the
+// progress given to Progress and ETA isn't reported. It has no effect when the XML output
+// is written to a file using ToFile.
+func WithLowMemoryParse() Option {
+	return func(s *Scanner) {
+		s.lowMemoryParse = true
+	}
+}
+
+// WithTimeout sets the maximal duration of the scan. The deadline is derived
+// from the context given to NewScanner, so that cancelling that context still
+// stops the scan. When the deadline is exceeded, Run returns ErrScanTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Scanner) {
+		s.timeout = timeout
+	}
+}
+
+// WithStatsCallback sets a function that is called with the run statistics
+// (hosts up and down, elapsed time, summary) as soon as nmap's output is
+// parsed, before the result is returned by Run.
+func WithStatsCallback(callback func(Stats)) Option {
+	return func(s *Scanner) {
+		s.statsCallback = callback
+	}
+}
+
+// WithSuppressWarnings drops the nmap warnings containing any of the given
+// patterns, so that benign warnings don't end up in the scan's warnings.
+// Warnings that indicate a failed scan, such as the ones returned as errors
+// by Run or Run.Err, are never dropped.
+func WithSuppressWarnings(patterns ...string) Option {
+	return func(s *Scanner) {
+		s.suppressedWarnings = append(s.suppressedWarnings, patterns...)
+	}
+}
+
+// WithScriptResultCallback sets a callback which is called with the scripts of each
+// host as soon as nmap is done with it, instead of once the whole scan is over. This
+// allows long running script scans to report their results incrementally.
+// The scripts are the host scripts followed by the scripts of each port, and hosts
+// without any script result are skipped. The callback is called from a separate
+// goroutine, and is not called when the XML output is written to a file using ToFile.
+func WithScriptResultCallback(callback func(Host, []Script)) Option {
+	return func(s *Scanner) {
+		s.hostCallbacks = append(s.hostCallbacks, func(host Host) {
+			scripts := append([]Script{}, host.HostScripts...)
+			for _, port := range host.Ports {
+				scripts = append(scripts, port.Scripts...)
+			}
+
+			if len(scripts) > 0 {
+				callback(host, scripts)
+			}
+		})
+	}
+}
+
+// WithBeforeRun sets a hook which is called with nmap's arguments right before
+// each scan is started, such as to start a tracing span or emit metrics.
+func WithBeforeRun(hook func(args []string)) Option {
+	return func(s *Scanner) {
+		s.beforeRun = append(s.beforeRun, hook)
+	}
+}
+
+// WithAfterRun sets a hook which is called once each scan is over, with its result
+// and the error returned by Run. The result is nil if nmap could not be started.
+// When running asynchronously, the hook is called before the error is sent
+// to the channel given to Async.
+func WithAfterRun(hook func(result *Run, err error)) Option {
+	return func(s *Scanner) {
+		s.afterRun = append(s.afterRun, hook)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"sync"
 	"testing"
@@ -24,6 +27,20 @@ func (c *testStreamer) Write(d []byte) (int, error) {
 	return len(d), nil
 }
 
+// failingStreamer is a streamer which fails after its first write.
+type failingStreamer struct {
+	writes int
+}
+
+// Write is a function that handles the normal nmap stdout.
+func (c *failingStreamer) Write(d []byte) (int, error) {
+	c.writes++
+	if c.writes > 1 {
+		return 0, errors.New("streamer closed")
+	}
+	return len(d), nil
+}
+
 func TestNmapNotInstalled(t *testing.T) {
 	oldPath := os.Getenv("PATH")
 	_ = os.Setenv("PATH", "")
@@ -78,7 +95,7 @@ func TestRun(t *testing.T) {
 			},
 
 			expectedErr:      true,
-			expectedWarnings: []string{"EOF"},
+			expectedWarnings: []string{`unable to parse nmap XML at offset 17 near "-oX - -- 0.0.0.0\n": EOF`},
 		},
 		{
 			description: "context timeout",
@@ -101,7 +118,7 @@ func TestRun(t *testing.T) {
 			},
 
 			expectedResult: &Run{
-				Args:    nmapPath + " -T5 -oX - localhost",
+				Args:    nmapPath + " -T5 -oX - -- localhost",
 				Scanner: "nmap",
 			},
 
@@ -237,6 +254,7 @@ func TestRun(t *testing.T) {
 
 			if test.compareWholeRun {
 				result.rawXML = nil
+				result.warnings = nil
 				if !reflect.DeepEqual(test.expectedResult, result) {
 					t.Errorf("expected result to be %+v, got %+v", test.expectedResult, result)
 				}
@@ -318,6 +336,340 @@ func TestRunWithProgress(t *testing.T) {
 	}
 }
 
+func TestRunWithProgressClamped(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_delay.sh"),
+		WithCustomArguments("tests/xml/scan_progress_overflow.xml"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	progress := make(chan float32, 100)
+	_, _, err = s.Progress(progress).Run()
+	assert.NoError(t, err)
+
+	var progressOutput []float32
+	for n := range progress {
+		assert.LessOrEqual(t, n, float32(100))
+		progressOutput = append(progressOutput, n)
+	}
+
+	assert.Contains(t, progressOutput, float32(100))
+}
+
+func TestRunWithProgressCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	s, err := NewScanner(
+		ctx,
+		WithBinaryPath("tests/scripts/fake_nmap_hang.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml", "20"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	// Nobody reads the progress, so streaming it blocks until the scan is cancelled.
+	progress := make(chan float32)
+	eta := make(chan time.Time)
+	_, _, err = s.Progress(progress).ETA(eta).Run()
+	assert.Error(t, err)
+
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-progress:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond, "expected progress channel to be closed")
+
+	assert.Eventually(t, func() bool {
+		select {
+		case _, ok := <-eta:
+			return !ok
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond, "expected ETA channel to be closed")
+}
+
+func TestRunWithProgressToFile(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_progress_file.sh"),
+		WithCustomArguments("tests/stdout/progress.txt", "tests/xml/scan_tcp_udp.xml"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	progress := make(chan float32, 100)
+	result, _, err := s.ToFile(filepath.Join(t.TempDir(), "output.xml")).Progress(progress).Run()
+	assert.NoError(t, err)
+	assert.Len(t, result.Hosts, 1)
+
+	var progressOutput []float32
+	for n := range progress {
+		progressOutput = append(progressOutput, n)
+	}
+
+	assert.Contains(t, progressOutput, float32(12.5))
+	assert.Contains(t, progressOutput, float32(100))
+}
+
+func TestRunWithETA(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_delay.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	eta := make(chan time.Time, 100)
+	progress := make(chan float32, 100)
+	_, _, err = s.ETA(eta).Progress(progress).Run()
+	assert.NoError(t, err)
+
+	expected := map[int64]bool{
+		1201479949: true, 1201479767: true, 1201479995: true, 1201480212: true, 1201480395: true,
+		1201480433: true, 1201480564: true, 1201480656: true, 1201480720: true, 1201480760: true,
+	}
+
+	var etaOutput []time.Time
+	for n := range eta {
+		assert.True(t, expected[n.Unix()], "unexpected ETA %s", n)
+		etaOutput = append(etaOutput, n)
+	}
+	// The ETA is polled, so updates written in quick succession can be missed.
+	assert.NotEmpty(t, etaOutput)
+
+	for i := 1; i < len(etaOutput); i++ {
+		assert.False(t, etaOutput[i].Equal(etaOutput[i-1]), "expected only changes of the ETA to be sent")
+	}
+
+	for range progress {
+	}
+}
+
+func TestRunWithETAToFile(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_progress_file.sh"),
+		WithCustomArguments("tests/stdout/progress.txt", "tests/xml/scan_tcp_udp.xml"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	start := time.Now().Truncate(time.Second)
+	eta := make(chan time.Time, 100)
+	_, _, err = s.ToFile(filepath.Join(t.TempDir(), "output.xml")).ETA(eta).Run()
+	assert.NoError(t, err)
+
+	var etaOutput []time.Time
+	for n := range eta {
+		etaOutput = append(etaOutput, n)
+	}
+
+	assert.NotEmpty(t, etaOutput)
+	assert.False(t, etaOutput[0].Before(start.Add(7*time.Second)), "expected the first ETA to account for 7 remaining seconds")
+	assert.True(t, etaOutput[0].Before(time.Now().Add(8*time.Second)))
+}
+
+func TestRunWithScriptResultCallback(t *testing.T) {
+	var results []string
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_delay.sh"),
+		WithCustomArguments("tests/xml/scan_scripts.xml"),
+		WithScriptResultCallback(func(host Host, scripts []Script) {
+			var ids []string
+			for _, script := range scripts {
+				ids = append(ids, script.ID)
+			}
+			results = append(results, host.Addresses[0].Addr+": "+strings.Join(ids, ","))
+		}),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+	assert.Len(t, result.Hosts, 3)
+
+	assert.Equal(t, []string{"192.168.1.1: smb2-time,http-title", "192.168.1.3: ssh-hostkey"}, results)
+}
+
+func TestRunWithFilterScript(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedScripts map[string][]string
+	}{
+		{
+			description: "keep http scripts",
+
+			options: []Option{
+				WithFilterScript(func(script Script) bool {
+					return strings.HasPrefix(script.ID, "http-")
+				}),
+			},
+
+			expectedScripts: map[string][]string{
+				"192.168.1.1:80": {"http-title"},
+			},
+		},
+		{
+			description: "port filter sees filtered scripts",
+
+			options: []Option{
+				WithFilterScript(func(script Script) bool {
+					return script.ID != "http-title"
+				}),
+				WithFilterPort(func(port Port) bool {
+					return len(port.Scripts) > 0
+				}),
+			},
+
+			expectedScripts: map[string][]string{
+				"192.168.1.1":    {"smb2-time"},
+				"192.168.1.3:22": {"ssh-hostkey"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			options := append([]Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_scripts.xml"),
+			}, test.options...)
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			result, _, err := s.Run()
+			assert.NoError(t, err)
+
+			scripts := make(map[string][]string)
+			for _, host := range result.Hosts {
+				addr := host.Addresses[0].Addr
+				for _, script := range host.HostScripts {
+					scripts[addr] = append(scripts[addr], script.ID)
+				}
+				for _, port := range host.Ports {
+					for _, script := range port.Scripts {
+						key := fmt.Sprintf("%s:%d", addr, port.ID)
+						scripts[key] = append(scripts[key], script.ID)
+					}
+				}
+			}
+			assert.Equal(t, test.expectedScripts, scripts)
+		})
+	}
+}
+
+func TestRunWithoutDownHosts(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedHosts []string
+	}{
+		{
+			description: "down hosts are kept by default",
+
+			expectedHosts: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3", "192.168.1.4"},
+		},
+		{
+			description: "down hosts are removed",
+
+			options: []Option{
+				WithoutDownHosts(),
+			},
+
+			expectedHosts: []string{"192.168.1.2", "192.168.1.4"},
+		},
+		{
+			description: "down hosts are removed along with host filter",
+
+			options: []Option{
+				WithoutDownHosts(),
+				WithFilterHost(func(host Host) bool {
+					return host.Addresses[0].Addr != "192.168.1.4"
+				}),
+			},
+
+			expectedHosts: []string{"192.168.1.2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			options := append([]Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_down_hosts.xml"),
+			}, test.options...)
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			result, _, err := s.Run()
+			assert.NoError(t, err)
+
+			var hosts []string
+			for _, host := range result.Hosts {
+				hosts = append(hosts, host.Addresses[0].Addr)
+			}
+			assert.Equal(t, test.expectedHosts, hosts)
+		})
+	}
+}
+
+func TestRunWithFailingStreamer(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_crash.sh"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.Streamer(&failingStreamer{}).Run()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		var exitErr *exec.ExitError
+		assert.ErrorAs(t, err, &exitErr)
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected Run to return when the streamer fails")
+	}
+}
+
+func TestClampProgress(t *testing.T) {
+	assert.Equal(t, float32(0), clampProgress(-0.5))
+	assert.Equal(t, float32(42.42), clampProgress(42.42))
+	assert.Equal(t, float32(100), clampProgress(100.01))
+}
+
 func TestRunWithStreamer(t *testing.T) {
 	streamer := &testStreamer{}
 
@@ -446,6 +798,7 @@ func TestRunAsync(t *testing.T) {
 
 			if test.compareWholeRun {
 				result.rawXML = nil
+				result.warnings = nil
 				if !reflect.DeepEqual(test.expectedResult, result) {
 					t.Errorf("expected result to be %+v, got %+v", test.expectedResult, result)
 				}
@@ -454,6 +807,125 @@ func TestRunAsync(t *testing.T) {
 	}
 }
 
+func TestRunAsyncWarnings(t *testing.T) {
+	tests := []struct {
+		description string
+
+		stderrFile string
+
+		expectedErr      error
+		expectedWarnings []string
+	}{
+		{
+			description: "successful scan with warnings",
+
+			stderrFile: "tests/stderr/packet_capture.txt",
+
+			expectedWarnings: []string{
+				"Starting Nmap 7.93 ( https://nmap.org ) at 2023-05-17 18:30 CEST",
+				"--------------- Timing report ---------------",
+				"hostgroups: min 1, max 100000",
+				"rtt-timeouts: init 1000, min 100, max 10000",
+				"---------------------------------------------",
+				"Initiating ARP Ping Scan at 18:30",
+				"Packet capture filter (device wlp2s0): arp and arp[18:4] = 0x3C219C52 and arp[22:2] = 0x1C0D",
+				"Completed ARP Ping Scan at 18:30, 0.04s elapsed (1 total hosts)",
+				"Packet capture filter (device wlp2s0): dst host 192.168.1.2 and (icmp or icmp6 or ((tcp or udp or sctp) and (src host 192.168.1.1)))",
+			},
+		},
+		{
+			description: "failed scan with warnings",
+
+			stderrFile: "tests/stderr/npcap_fatal.txt",
+
+			expectedErr: ErrPcapMissing,
+			expectedWarnings: []string{
+				"Starting Nmap 7.94 ( https://nmap.org ) at 2023-06-12 10:03 W. Europe Daylight Time",
+				"Call to pcap_open_live() failed three times. There are several possible reasons for this, depending on your operating system:",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml", test.stderrFile),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			done := make(chan error)
+			result, warnings, err := s.Async(done).Run()
+			assert.NoError(t, err)
+
+			err = <-done
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectedWarnings, *warnings)
+			assert.Equal(t, test.expectedWarnings, result.Warnings())
+		})
+	}
+}
+
+func TestBuildArgs(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedArgs []string
+	}{
+		{
+			description: "targets are placed after a separator",
+
+			options: []Option{
+				WithTargets("192.168.0.1", "scanme.nmap.org"),
+				WithTimingTemplate(TimingFastest),
+			},
+
+			expectedArgs: []string{"-T5", "-oX", "-", "--", "192.168.0.1", "scanme.nmap.org"},
+		},
+		{
+			description: "no separator without targets",
+
+			options: []Option{
+				WithTargetInput("/targets.txt"),
+			},
+
+			expectedArgs: []string{"-iL", "/targets.txt", "-oX", "-"},
+		},
+		{
+			description: "unmanaged output",
+
+			options: []Option{
+				WithTargets("192.168.0.1"),
+				WithCustomArguments("-oX", "/scan.xml"),
+				WithUnmanagedOutput(),
+			},
+
+			expectedArgs: []string{"-oX", "/scan.xml", "--", "192.168.0.1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), test.options...)
+			if err != nil {
+				panic(err)
+			}
+
+			assert.Equal(t, test.expectedArgs, s.buildArgs())
+		})
+	}
+}
+
 func TestCheckStdErr(t *testing.T) {
 	tests := []struct {
 		description string
@@ -473,6 +945,36 @@ func TestCheckStdErr(t *testing.T) {
 			warnings:    []string{"Malloc Failed! with"},
 			expectedErr: ErrMallocFailed,
 		},
+		{
+			description: "Find Npcap error",
+			stderr:      "Starting Nmap 7.94\nNmap requires Npcap to be installed. ",
+			warnings:    []string{"Starting Nmap 7.94", "Nmap requires Npcap to be installed."},
+			expectedErr: ErrPcapMissing,
+		},
+		{
+			description: "Find pcap_open_live error",
+			stderr:      "Call to pcap_open_live() failed three times.\nQUITTING!",
+			warnings:    []string{"Call to pcap_open_live() failed three times."},
+			expectedErr: ErrPcapMissing,
+		},
+		{
+			description: "Skip Npcap connect() fallback warning",
+			stderr:      "WARNING: Could not import all necessary Npcap functions. Resorting to connect() mode -- Nmap may not function completely",
+			warnings:    []string{"WARNING: Could not import all necessary Npcap functions. Resorting to connect() mode -- Nmap may not function completely"},
+			expectedErr: nil,
+		},
+		{
+			description: "Find dnet interface error",
+			stderr:      "dnet: Failed to open device eth5\nQUITTING!",
+			warnings:    []string{"dnet: Failed to open device eth5"},
+			expectedErr: ErrInterfaceNotFound,
+		},
+		{
+			description: "Skip script trace",
+			stderr:      "NSE: TCP 127.0.0.1:4242 > 127.0.0.1:80 | CONNECT\nNoWarning",
+			warnings:    []string{"NoWarning"},
+			expectedErr: nil,
+		},
 	}
 
 	for _, test := range tests {
@@ -482,12 +984,749 @@ func TestCheckStdErr(t *testing.T) {
 			var warnings []string
 			err := checkStdErr(&buf, &warnings)
 
-			assert.Equal(t, test.expectedErr, err)
+			assert.ErrorIs(t, err, test.expectedErr)
 			assert.True(t, reflect.DeepEqual(test.warnings, warnings))
 		})
 	}
 }
 
+func TestRunWithTimeout(t *testing.T) {
+	tests := []struct {
+		description string
+
+		timeout time.Duration
+
+		expectedErr error
+	}{
+		{
+			description: "scan exceeds timeout",
+
+			timeout: 50 * time.Millisecond,
+
+			expectedErr: ErrScanTimeout,
+		},
+		{
+			description: "scan finishes before timeout",
+
+			timeout: 10 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.Background(),
+				WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+				WithCustomArguments("0.5", "tests/xml/scan_base.xml"),
+				WithTimeout(test.timeout),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, _, err = s.Run()
+			assert.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestRunWithTimeoutDerivedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, err := NewScanner(
+		ctx,
+		WithBinaryPath("tests/scripts/fake_nmap_sleep.sh"),
+		WithCustomArguments("0.5", "tests/xml/scan_base.xml"),
+		WithTimeout(10*time.Second),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	// The caller's context is already cancelled, so the scan can't even start.
+	_, _, err = s.Run()
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunWithStatsCallback(t *testing.T) {
+	var calls int
+	var stats Stats
+
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml"),
+		WithStatsCallback(func(s Stats) {
+			calls++
+			stats = s
+		}),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, HostStats{Up: 8, Down: 0, Total: 8}, stats.Hosts)
+	assert.Equal(t, "Sun Jan 27 21:52:49 2008", stats.Finished.TimeStr)
+	assert.Equal(t, result.Stats, stats)
+}
+
+func TestRunErr(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml", "tests/stderr/failed_to_resolve.txt"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, warnings, err := s.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, *warnings, result.Warnings())
+	assert.Len(t, result.Warnings(), 2)
+
+	// Failing to resolve one of the targets isn't fatal, for both the scan and its result.
+	assert.NoError(t, result.Err())
+}
+
+func TestRunPcapMissing(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml", "tests/stderr/npcap_fatal.txt"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, warnings, err := s.Run()
+	assert.ErrorIs(t, err, ErrPcapMissing)
+	assert.Contains(t, err.Error(), "https://npcap.com")
+	assert.Len(t, *warnings, 2)
+}
+
+func TestRunPcapFallback(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml", "tests/stderr/npcap_missing.txt"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	// Nmap falls back to connect() scans without Npcap, which still produce results.
+	result, warnings, err := s.Run()
+	assert.NoError(t, err)
+	assert.NoError(t, result.Err())
+	assert.Len(t, *warnings, 2)
+	assert.NotEmpty(t, result.Hosts)
+}
+
+func TestRunScanError(t *testing.T) {
+	tests := []struct {
+		description string
+
+		xmlFile string
+
+		expectedMessage string
+		expectedErr     error
+	}{
+		{
+			description: "error resolving name",
+
+			xmlFile: "tests/xml/scan_error_resolving_name.xml",
+
+			expectedMessage: "Error resolving name localhost",
+			expectedErr:     ErrResolveName,
+		},
+		{
+			description: "no targets",
+
+			xmlFile: "tests/xml/scan_error_no_targets.xml",
+
+			expectedMessage: "No targets were specified, so 0 hosts scanned.",
+			expectedErr:     ErrInvalidTarget,
+		},
+		{
+			description: "unknown error",
+
+			xmlFile: "tests/xml/scan_error_other.xml",
+
+			expectedMessage: "Unsupported error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments(test.xmlFile),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, _, err = s.Run()
+
+			var scanErr *ErrScanError
+			if !errors.As(err, &scanErr) {
+				t.Fatalf("expected an ErrScanError, got %v", err)
+			}
+
+			assert.Equal(t, test.expectedMessage, scanErr.Message)
+			assert.Contains(t, err.Error(), test.expectedMessage)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+			} else {
+				assert.Nil(t, errors.Unwrap(err))
+			}
+		})
+	}
+}
+
+func TestRunInterrupted(t *testing.T) {
+	tests := []struct {
+		description string
+
+		exitCode string
+
+		expectedInterrupt bool
+	}{
+		{
+			description: "SIGINT",
+
+			exitCode: "130",
+
+			expectedInterrupt: true,
+		},
+		{
+			description: "SIGTERM",
+
+			exitCode: "143",
+
+			expectedInterrupt: true,
+		},
+		{
+			description: "generic error",
+
+			exitCode: "1",
+
+			expectedInterrupt: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_exit.sh"),
+				WithCustomArguments(test.exitCode),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, _, err = s.Run()
+			assert.Error(t, err)
+			assert.Equal(t, test.expectedInterrupt, errors.Is(err, ErrScanInterrupt))
+			assert.Equal(t, test.expectedInterrupt, isInterruptExit(err))
+
+			var exitErr *exec.ExitError
+			assert.ErrorAs(t, err, &exitErr)
+		})
+	}
+}
+
+func TestRunInterruptedBySignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals only exist on Unix")
+	}
+
+	for _, signal := range []string{"INT", "TERM"} {
+		t.Run(signal, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_signal.sh"),
+				WithCustomArguments(signal),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, _, err = s.Run()
+			assert.ErrorIs(t, err, ErrScanInterrupt)
+			assert.True(t, isInterruptExit(err))
+		})
+	}
+
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_signal.sh"),
+		WithCustomArguments("KILL"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, _, err = s.Run()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrScanInterrupt)
+}
+
+func TestRunInterfaceNotFound(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_fail.sh"),
+		WithCustomArguments("tests/stderr/dnet_failed.txt"),
+		WithInterface("eth5"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, warnings, err := s.Run()
+	assert.ErrorIs(t, err, ErrInterfaceNotFound)
+	assert.Contains(t, err.Error(), "eth5")
+	assert.Contains(t, *warnings, "dnet: Failed to open device eth5")
+}
+
+func TestRunToFileAppendOutput(t *testing.T) {
+	previousRun, err := os.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "output.xml")
+	if err := os.WriteFile(outputFile, previousRun, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_append.sh"),
+		WithCustomArguments("tests/xml/scan_down_hosts.xml"),
+		WithAppendOutput(),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.ToFile(outputFile).Run()
+	assert.NoError(t, err)
+
+	latestRun, err := os.ReadFile("tests/xml/scan_down_hosts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, append(previousRun, latestRun...), content)
+	assert.Len(t, result.Hosts, 4)
+	assert.Len(t, result.DownHosts(), 2)
+}
+
+func TestRunToFileWithOutputFileMode(t *testing.T) {
+	for _, mode := range []os.FileMode{0600, 0644, 0640} {
+		t.Run(mode.String(), func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_progress_file.sh"),
+				WithCustomArguments("tests/stdout/progress.txt", "tests/xml/scan_tcp_udp.xml"),
+				WithOutputFileMode(mode),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			outputFile := filepath.Join(t.TempDir(), "output.xml")
+			_, _, err = s.ToFile(outputFile).Run()
+			assert.NoError(t, err)
+
+			info, err := os.Stat(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, mode, info.Mode().Perm())
+		})
+	}
+}
+
+func TestRunWithOutputFileModeWithoutFile(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml"),
+		WithOutputFileMode(0644),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	_, _, err = s.Run()
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestRunWithScanID(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedErr bool
+	}{
+		{
+			description: "successful scan",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml"),
+			},
+		},
+		{
+			description: "failed scan",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_error_other.xml"),
+			},
+
+			expectedErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), append(test.options, WithScanID("request-1234"))...)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+			assert.NotContains(t, s.Args(), "request-1234")
+
+			result, _, err := s.Run()
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, "request-1234", result.ScanID())
+		})
+	}
+}
+
+func TestRunWithUnmanagedOutput(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+		WithCustomArguments("tests/stdout/progress.txt", "tests/stderr/packet_capture.txt"),
+		WithUnmanagedOutput(),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+	assert.Empty(t, result.Hosts)
+	assert.Equal(t, "wlp2s0", result.SourceInterface())
+}
+
+func TestRunToFileWithoutOutput(t *testing.T) {
+	emptyFile := filepath.Join(t.TempDir(), "empty.xml")
+	if err := os.WriteFile(emptyFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+
+		outputFile string
+	}{
+		{
+			description: "output file in a non-writable directory",
+
+			outputFile: filepath.Join(t.TempDir(), "does_not_exist", "output.xml"),
+		},
+		{
+			description: "empty output file",
+
+			outputFile: emptyFile,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml"),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, _, err = s.ToFile(test.outputFile).Run()
+			assert.ErrorIs(t, err, ErrNoOutput)
+			assert.NotErrorIs(t, err, ErrParseOutput)
+			assert.Contains(t, err.Error(), test.outputFile)
+		})
+	}
+}
+
+func TestRunWithSuppressWarnings(t *testing.T) {
+	tests := []struct {
+		description string
+
+		stderrFile string
+		patterns   []string
+
+		expectedErr      error
+		expectedWarnings []string
+	}{
+		{
+			description: "benign warnings are dropped",
+
+			stderrFile: "tests/stderr/failed_to_resolve.txt",
+			patterns:   []string{"No targets were specified"},
+
+			expectedWarnings: []string{`Failed to resolve "domain.does.not.exist".`},
+		},
+		{
+			description: "targets which failed to resolve are dropped",
+
+			stderrFile: "tests/stderr/failed_to_resolve.txt",
+			patterns:   []string{"WARNING", "Failed to resolve"},
+
+			expectedWarnings: []string{},
+		},
+		{
+			description: "errors are still returned",
+
+			stderrFile: "tests/stderr/npcap_fatal.txt",
+			patterns:   []string{"Starting Nmap", "pcap"},
+
+			expectedErr: ErrPcapMissing,
+			expectedWarnings: []string{
+				"Call to pcap_open_live() failed three times. There are several possible reasons for this, depending on your operating system:",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml", test.stderrFile),
+				WithSuppressWarnings(test.patterns...),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			_, warnings, err := s.Run()
+			assert.ErrorIs(t, err, test.expectedErr)
+			assert.Equal(t, test.expectedWarnings, *warnings)
+		})
+	}
+}
+
+func TestRunWithHooks(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options      []Option
+		addedOptions []Option
+
+		expectedCalls  []string
+		expectedArgs   []string
+		expectedResult bool
+		expectedErr    bool
+	}{
+		{
+			description: "successful scan",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml"),
+			},
+
+			expectedCalls:  []string{"before", "after"},
+			expectedArgs:   []string{"tests/xml/scan_base.xml", "-oX", "-"},
+			expectedResult: true,
+		},
+		{
+			description: "failed scan",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml", "tests/stderr/npcap_fatal.txt"),
+			},
+
+			expectedCalls:  []string{"before", "after"},
+			expectedArgs:   []string{"tests/xml/scan_base.xml", "tests/stderr/npcap_fatal.txt", "-oX", "-"},
+			expectedResult: true,
+			expectedErr:    true,
+		},
+		{
+			description: "nmap can't be started",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/does_not_exist.sh"),
+				WithTargets("localhost"),
+			},
+
+			expectedCalls: []string{"before", "after"},
+			expectedArgs:  []string{"-oX", "-", "--", "localhost"},
+			expectedErr:   true,
+		},
+		{
+			description: "invalid added options",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithTargets("localhost"),
+			},
+			addedOptions: []Option{
+				WithMaxRetries(-1),
+			},
+
+			expectedCalls: []string{"after"},
+			expectedErr:   true,
+		},
+		{
+			description: "output file mode without output file",
+
+			options: []Option{
+				WithBinaryPath("tests/scripts/fake_nmap.sh"),
+				WithTargets("localhost"),
+				WithOutputFileMode(0644),
+			},
+
+			expectedCalls: []string{"after"},
+			expectedErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var calls []string
+			var hookArgs []string
+			var hookResult *Run
+			var hookErr error
+
+			options := append(test.options,
+				WithBeforeRun(func(args []string) {
+					calls = append(calls, "before")
+					hookArgs = args
+				}),
+				WithAfterRun(func(result *Run, err error) {
+					calls = append(calls, "after")
+					hookResult, hookErr = result, err
+				}),
+			)
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			// Options added after the scanner was created are only validated by Run.
+			s.AddOptions(test.addedOptions...)
+
+			result, _, err := s.Run()
+
+			assert.Equal(t, test.expectedCalls, calls)
+			assert.Equal(t, test.expectedArgs, hookArgs)
+			assert.Equal(t, err, hookErr)
+			assert.Equal(t, test.expectedErr, hookErr != nil)
+			assert.Equal(t, test.expectedResult, hookResult != nil)
+			if test.expectedResult {
+				assert.Same(t, result, hookResult)
+			}
+		})
+	}
+}
+
+func TestRunSourceInterface(t *testing.T) {
+	tests := []struct {
+		description string
+
+		stderrFile string
+
+		expectedInterface string
+	}{
+		{
+			description: "debugging output mentioning the device",
+
+			stderrFile: "tests/stderr/packet_capture.txt",
+
+			expectedInterface: "wlp2s0",
+		},
+		{
+			description: "no debugging output",
+
+			stderrFile: "tests/stderr/failed_to_resolve.txt",
+
+			expectedInterface: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+				WithCustomArguments("tests/xml/scan_base.xml", test.stderrFile),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			result, _, err := s.Run()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedInterface, result.SourceInterface())
+		})
+	}
+}
+
+func TestRunWithScriptTrace(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stderr.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml", "tests/stderr/script_trace.txt"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, warnings, err := s.Run()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"WARNING: No targets were specified, so 0 hosts scanned."}, *warnings)
+	assert.Len(t, result.ScriptTrace(), 4)
+	assert.Equal(t, "NSE: TCP 192.168.1.2:51234 > 192.168.1.1:80 | CONNECT", result.ScriptTrace()[0])
+}
+
+func TestRunErrWithoutFatalWarnings(t *testing.T) {
+	r := Run{warnings: []string{"WARNING: No targets were specified, so 0 hosts scanned."}}
+	assert.NoError(t, r.Err())
+
+	r.warnings = append(r.warnings, "Malloc Failed! with ")
+	assert.ErrorIs(t, r.Err(), ErrMallocFailed)
+}
+
 // Test to verify the fix for a race condition works
 // See: https://github.com/Ullaakut/nmap/issues/122
 func TestParseXMLOutputRaceCondition(t *testing.T) {
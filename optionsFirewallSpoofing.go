@@ -64,6 +64,7 @@ func WithSpoofIPAddress(ip string) Option {
 }
 
 // WithInterface specifies which network interface to use for scanning.
+// It is required when scanning IPv6 link-local targets.
 func WithInterface(iface string) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "-e")
@@ -142,7 +143,8 @@ func WithIPTimeToLive(ttl int16) Option {
 // WithSendEthernet to ensure that Nmap actually sends ethernet-level
 // packets.
 // Valid argument examples are Apple, 0, 01:02:03:04:05:06,
-// deadbeefcafe, 0020F2, and Cisco.
+// deadbeefcafe, 0020F2, and Cisco. Any other argument makes
+// NewScanner return ErrInvalidOption.
 func WithSpoofMAC(argument string) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "--spoof-mac")
@@ -13,6 +13,7 @@ func WithListScan() Option {
 }
 
 // WithPingScan sets the discovery mode to simply ping the targets to scan and not scan them.
+// Since no port is scanned, it can't be used along with WithPorts.
 func WithPingScan() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "-sn")
@@ -20,12 +21,25 @@ func WithPingScan() Option {
 }
 
 // WithSkipHostDiscovery disables host discovery and considers all hosts as online.
+// It can't be combined with host discovery probes such as WithSYNDiscovery, since
+// those would never be sent.
 func WithSkipHostDiscovery() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "-Pn")
 	}
 }
 
+// WithKnownHostsUp disables both DNS resolution and host discovery, which
+// is equivalent to using WithDisabledDNSResolution and WithSkipHostDiscovery.
+// This is the fastest configuration when scanning a list of hosts that are
+// known to be up, such as in CI or lab environments, since nmap neither
+// probes the hosts nor waits for reverse DNS lookups before scanning them.
+func WithKnownHostsUp() Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-n", "-Pn")
+	}
+}
+
 // WithSYNDiscovery sets the discovery mode to use SYN packets.
 // If the portList argument is empty, this will enable SYN discovery
 // for all ports. Otherwise, it will be only for the specified ports.
@@ -119,6 +133,100 @@ func WithIPProtocolPingDiscovery(protocols ...string) Option {
 	}
 }
 
+// DiscoveryType represents a host discovery probe type.
+type DiscoveryType int
+
+// Discovery type enumerations.
+const (
+	DiscoverySYN DiscoveryType = iota
+	DiscoveryACK
+	DiscoveryUDP
+	DiscoverySCTP
+	DiscoveryICMPEcho
+	DiscoveryICMPTimestamp
+	DiscoveryICMPNetMask
+	DiscoveryIPProtocol
+)
+
+// discoveryFlags associates each discovery type with its nmap flag.
+var discoveryFlags = map[DiscoveryType]string{
+	DiscoverySYN:           "-PS",
+	DiscoveryACK:           "-PA",
+	DiscoveryUDP:           "-PU",
+	DiscoverySCTP:          "-PY",
+	DiscoveryICMPEcho:      "-PE",
+	DiscoveryICMPTimestamp: "-PP",
+	DiscoveryICMPNetMask:   "-PM",
+	DiscoveryIPProtocol:    "-PO",
+}
+
+// DiscoveryProbe is a host discovery probe, optionally restricted to
+// a list of ports. A DiscoveryType is itself a probe using nmap's
+// default ports.
+type DiscoveryProbe interface {
+	probe() (DiscoveryType, []string)
+}
+
+type discoveryPorts struct {
+	discoveryType DiscoveryType
+	ports         []string
+}
+
+func (d discoveryPorts) probe() (DiscoveryType, []string) {
+	return d.discoveryType, d.ports
+}
+
+func (d DiscoveryType) probe() (DiscoveryType, []string) {
+	return d, nil
+}
+
+// Ports returns a probe of this type sent to the given ports. For
+// DiscoveryIPProtocol, the ports are IP protocol numbers. ICMP probe
+// types don't use ports and ignore them.
+func (d DiscoveryType) Ports(ports ...string) DiscoveryProbe {
+	return discoveryPorts{discoveryType: d, ports: ports}
+}
+
+// WithDiscovery sets the discovery mode to use all of the given probes.
+// Probes of the same type are combined into a single flag, so that
+// WithDiscovery(DiscoverySYN.Ports("22"), DiscoverySYN.Ports("443"), DiscoveryICMPEcho)
+// results in -PS22,443 -PE.
+func WithDiscovery(probes ...DiscoveryProbe) Option {
+	var types []DiscoveryType
+	ports := make(map[DiscoveryType][]string)
+	seen := make(map[DiscoveryType]map[string]bool)
+	for _, probe := range probes {
+		discoveryType, probePorts := probe.probe()
+		if _, ok := discoveryFlags[discoveryType]; !ok {
+			panic("value given to nmap.WithDiscovery() should be a known DiscoveryType")
+		}
+
+		if _, ok := seen[discoveryType]; !ok {
+			types = append(types, discoveryType)
+			seen[discoveryType] = make(map[string]bool)
+		}
+
+		for _, port := range probePorts {
+			if !seen[discoveryType][port] {
+				seen[discoveryType][port] = true
+				ports[discoveryType] = append(ports[discoveryType], port)
+			}
+		}
+	}
+
+	return func(s *Scanner) {
+		for _, discoveryType := range types {
+			flag := discoveryFlags[discoveryType]
+			switch discoveryType {
+			case DiscoveryICMPEcho, DiscoveryICMPTimestamp, DiscoveryICMPNetMask:
+				s.args = append(s.args, flag)
+			default:
+				s.args = append(s.args, flag+strings.Join(ports[discoveryType], ","))
+			}
+		}
+	}
+}
+
 // WithDisabledDNSResolution disables DNS resolution in the discovery
 // step of the nmap scan.
 func WithDisabledDNSResolution() Option {
@@ -179,6 +179,56 @@ func TestHostDiscovery(t *testing.T) {
 				"-PO1,2,4",
 			},
 		},
+		{
+			description: "combined discovery with default ports",
+
+			options: []Option{
+				WithDiscovery(DiscoverySYN, DiscoveryACK, DiscoveryICMPEcho),
+			},
+
+			expectedArgs: []string{
+				"-PS",
+				"-PA",
+				"-PE",
+			},
+		},
+		{
+			description: "combined discovery with ports per type",
+
+			options: []Option{
+				WithDiscovery(
+					DiscoverySYN.Ports("22", "443"),
+					DiscoveryUDP.Ports("53"),
+					DiscoveryIPProtocol.Ports("1", "2"),
+					DiscoveryICMPTimestamp,
+				),
+			},
+
+			expectedArgs: []string{
+				"-PS22,443",
+				"-PU53",
+				"-PO1,2",
+				"-PP",
+			},
+		},
+		{
+			description: "combined discovery merges probes of the same type",
+
+			options: []Option{
+				WithDiscovery(
+					DiscoverySYN.Ports("22"),
+					DiscoverySCTP,
+					DiscoverySYN.Ports("443", "22"),
+					DiscoveryICMPNetMask.Ports("80"),
+				),
+			},
+
+			expectedArgs: []string{
+				"-PS22,443",
+				"-PY",
+				"-PM",
+			},
+		},
 		{
 			description: "disable DNS resolution during discovery",
 
@@ -224,6 +274,18 @@ func TestHostDiscovery(t *testing.T) {
 				"--system-dns",
 			},
 		},
+		{
+			description: "known hosts up - skip DNS resolution and host discovery",
+
+			options: []Option{
+				WithKnownHostsUp(),
+			},
+
+			expectedArgs: []string{
+				"-n",
+				"-Pn",
+			},
+		},
 		{
 			description: "traceroute",
 
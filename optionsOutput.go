@@ -44,8 +44,19 @@ func WithPacketTrace() Option {
 	}
 }
 
+// WithScriptKiddieOutput makes nmap write its output in the s|<rIpt kIddi3
+// format into the given file. It does not affect the XML output parsed by
+// this library.
+func WithScriptKiddieOutput(filePath string) Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-oS")
+		s.args = append(s.args, filePath)
+	}
+}
+
 // WithAppendOutput makes nmap append to files instead of overwriting them.
-// Currently does nothing, since this library doesn't write in files.
+// When used along with Scanner.ToFile, only the latest run appended to the
+// file is parsed.
 func WithAppendOutput() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "--append-output")
@@ -54,6 +65,8 @@ func WithAppendOutput() Option {
 
 // WithResumePreviousScan makes nmap continue a scan that was aborted,
 // from an output file.
+// The file should be a normal or grepable nmap output, otherwise NewScanner
+// returns ErrInvalidOption.
 func WithResumePreviousScan(filePath string) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "--resume")
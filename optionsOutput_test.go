@@ -69,6 +69,18 @@ func TestOutput(t *testing.T) {
 				"--packet-trace",
 			},
 		},
+		{
+			description: "script kiddie output",
+
+			options: []Option{
+				WithScriptKiddieOutput("/nmap_scan.txt"),
+			},
+
+			expectedArgs: []string{
+				"-oS",
+				"/nmap_scan.txt",
+			},
+		},
 		{
 			description: "enable appending output",
 
@@ -84,12 +96,12 @@ func TestOutput(t *testing.T) {
 			description: "resume scan from file",
 
 			options: []Option{
-				WithResumePreviousScan("/nmap_scan.xml"),
+				WithResumePreviousScan("tests/resume/scan_interrupted.nmap"),
 			},
 
 			expectedArgs: []string{
 				"--resume",
-				"/nmap_scan.xml",
+				"tests/resume/scan_interrupted.nmap",
 			},
 		},
 		{
@@ -6,41 +6,129 @@ import (
 )
 
 // WithPorts sets the ports which the scanner should scan on each host.
+// Ports given over multiple calls, or along with WithTCPPorts and
+// WithUDPPorts, are merged into a single port specification.
 func WithPorts(ports ...string) Option {
-	portList := strings.Join(ports, ",")
+	return func(s *Scanner) {
+		s.addPorts("", ports)
+	}
+}
 
+// WithTCPPorts sets the TCP ports which the scanner should scan on each host.
+// It can be combined with WithUDPPorts to scan different ports for each
+// protocol, which results in a specification such as T:80,443,U:53,161.
+// A TCP scan type, such as WithSYNScan, is required for these ports to be scanned.
+func WithTCPPorts(ports ...string) Option {
 	return func(s *Scanner) {
-		// Find if any port is set.
-		var place = -1
-		for p, value := range s.args {
-			if value == "-p" {
-				place = p
-				break
-			}
+		s.addPorts("T", ports)
+	}
+}
+
+// WithUDPPorts sets the UDP ports which the scanner should scan on each host.
+// It can be combined with WithTCPPorts to scan different ports for each
+// protocol, which results in a specification such as T:80,443,U:53,161.
+// WithUDPScan is required for these ports to be scanned.
+func WithUDPPorts(ports ...string) Option {
+	return func(s *Scanner) {
+		s.addPorts("U", ports)
+	}
+}
+
+// portProtocols are the protocol qualifiers of nmap's port specification,
+// in the order in which they are written.
+var portProtocols = []string{"", "T", "U", "S", "P"}
+
+// addPorts merges the given ports for the given protocol qualifier into the
+// port specification of the scanner.
+func (s *Scanner) addPorts(protocol string, ports []string) {
+	addition := strings.Join(ports, ",")
+	if protocol != "" {
+		addition = protocol + ":" + addition
+	}
+
+	s.addPortSpec("-p", addition)
+}
+
+// addPortSpec merges the given port specification into the value of the
+// given flag, since nmap only takes the last occurrence of a flag into account.
+func (s *Scanner) addPortSpec(flag, addition string) {
+	// Find if any port is set.
+	var place = -1
+	for p, value := range s.args {
+		if value == flag {
+			place = p
+			break
 		}
+	}
 
-		// Add ports.
-		if place >= 0 {
-			if len(s.args)-1 == place {
-				s.args = append(s.args, "")
-			} else {
-				portList = s.args[place+1] + "," + portList
+	// Add ports.
+	switch {
+	case place < 0:
+		s.args = append(s.args, flag, mergePortSpecs(addition))
+	case len(s.args)-1 == place:
+		s.args = append(s.args, mergePortSpecs(addition))
+	default:
+		s.args[place+1] = mergePortSpecs(s.args[place+1], addition)
+	}
+}
+
+// mergePortSpecs merges port specifications into a single one, grouping
+// the ports by protocol qualifier and dropping duplicates. Unqualified ports
+// are written first, since they would otherwise be attributed to the
+// preceding protocol.
+func mergePortSpecs(specs ...string) string {
+	groups := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		// Each specification starts without any protocol qualifier.
+		var protocol string
+		for _, entry := range strings.Split(spec, ",") {
+			if qualifier, ports, found := strings.Cut(entry, ":"); found && isPortProtocol(qualifier) {
+				protocol = qualifier
+				entry = ports
 			}
-			s.args[place+1] = portList
-		} else {
-			s.args = append(s.args, "-p")
-			s.args = append(s.args, portList)
+			if entry == "" || seen[protocol+":"+entry] {
+				continue
+			}
+
+			seen[protocol+":"+entry] = true
+			groups[protocol] = append(groups[protocol], entry)
 		}
 	}
+
+	var merged []string
+	for _, protocol := range portProtocols {
+		if len(groups[protocol]) == 0 {
+			continue
+		}
+
+		group := strings.Join(groups[protocol], ",")
+		if protocol != "" {
+			group = protocol + ":" + group
+		}
+		merged = append(merged, group)
+	}
+
+	return strings.Join(merged, ",")
+}
+
+// isPortProtocol returns whether the given string is a protocol qualifier
+// of nmap's port specification.
+func isPortProtocol(qualifier string) bool {
+	for _, protocol := range portProtocols {
+		if protocol != "" && protocol == qualifier {
+			return true
+		}
+	}
+
+	return false
 }
 
 // WithPortExclusions sets the ports that the scanner should not scan on each host.
+// Ports given over multiple calls are merged into a single exclusion list.
 func WithPortExclusions(ports ...string) Option {
-	portList := strings.Join(ports, ",")
-
 	return func(s *Scanner) {
-		s.args = append(s.args, "--exclude-ports")
-		s.args = append(s.args, portList)
+		s.addPortSpec("--exclude-ports", strings.Join(ports, ","))
 	}
 }
 
@@ -52,7 +140,7 @@ func WithFastMode() Option {
 }
 
 // WithConsecutivePortScanning makes the scan go through ports consecutively instead of
-// picking them out randomly.
+// picking them out randomly, which helps debugging and scanning rate-sensitive targets.
 func WithConsecutivePortScanning() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "-r")
@@ -28,6 +28,73 @@ func TestPortSpecAndScanOrder(t *testing.T) {
 				"554,8554,80-81",
 			},
 		},
+		{
+			description: "specify the same ports over multiple calls",
+
+			options: []Option{
+				WithPorts("22", "80"),
+				WithTimingTemplate(TimingAggressive),
+				WithPorts("80", "443"),
+			},
+
+			expectedArgs: []string{
+				"-p",
+				"22,80,443",
+				"-T4",
+			},
+		},
+		{
+			description: "specify TCP ports to scan",
+
+			options: []Option{
+				WithTCPPorts("80", "443"),
+			},
+
+			expectedArgs: []string{
+				"-p",
+				"T:80,443",
+			},
+		},
+		{
+			description: "specify UDP ports to scan",
+
+			options: []Option{
+				WithUDPPorts("53", "161"),
+			},
+
+			expectedArgs: []string{
+				"-p",
+				"U:53,161",
+			},
+		},
+		{
+			description: "specify TCP and UDP ports to scan",
+
+			options: []Option{
+				WithUDPPorts("53"),
+				WithTCPPorts("80", "443"),
+				WithUDPPorts("161"),
+			},
+
+			expectedArgs: []string{
+				"-p",
+				"T:80,443,U:53,161",
+			},
+		},
+		{
+			description: "specify ports for any protocol along with TCP and UDP ports",
+
+			options: []Option{
+				WithTCPPorts("80"),
+				WithUDPPorts("53"),
+				WithPorts("22"),
+			},
+
+			expectedArgs: []string{
+				"-p",
+				"22,T:80,U:53",
+			},
+		},
 		{
 			description: "exclude ports to scan",
 
@@ -40,6 +107,22 @@ func TestPortSpecAndScanOrder(t *testing.T) {
 				"554,8554",
 			},
 		},
+		{
+			description: "exclude ports over multiple calls",
+
+			options: []Option{
+				WithPortExclusions("554"),
+				WithPorts("1-1024"),
+				WithPortExclusions("8554", "554"),
+			},
+
+			expectedArgs: []string{
+				"--exclude-ports",
+				"554,8554",
+				"-p",
+				"1-1024",
+			},
+		},
 		{
 			description: "fast mode - scan fewer ports than the default scan",
 
@@ -172,7 +172,8 @@ func WithSCTPCookieEchoScan() Option {
 // IP protocol scan allows you to determine which IP protocols
 // (TCP, ICMP, IGMP, etc.) are supported by target machines. This isn't
 // technically a port scan, since it cycles through IP protocol numbers
-// rather than TCP or UDP port numbers.
+// rather than TCP or UDP port numbers. Ports given using WithPorts are
+// interpreted as protocol numbers, which range from 0 to 255.
 func WithIPProtocolScan() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "-sO")
@@ -191,3 +192,29 @@ func WithFTPBounceScan(FTPRelayHost string) Option {
 		s.args = append(s.args, FTPRelayHost)
 	}
 }
+
+// scanTechniqueFlags are the flags which select a scan technique.
+var scanTechniqueFlags = []string{"-sS", "-sT", "-sA", "-sW", "-sM", "-sU", "-sN", "-sF", "-sX", "-sI", "-sY", "-sZ", "-sO", "-b"}
+
+// ScanTechniques returns the flags of the scan techniques that the scanner
+// uses, such as -sS and -sU, in the order in which they were set. It is empty
+// when no technique was set, in which case nmap picks its default one.
+func (s *Scanner) ScanTechniques() []string {
+	var techniques []string
+	seen := make(map[string]bool)
+	for _, arg := range s.args {
+		if seen[arg] {
+			continue
+		}
+
+		for _, flag := range scanTechniqueFlags {
+			if arg == flag {
+				seen[arg] = true
+				techniques = append(techniques, arg)
+				break
+			}
+		}
+	}
+
+	return techniques
+}
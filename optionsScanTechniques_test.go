@@ -209,3 +209,56 @@ func TestScanTechniques(t *testing.T) {
 		})
 	}
 }
+
+func TestScannerScanTechniques(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedTechniques []string
+	}{
+		{
+			description: "SYN and UDP scans",
+
+			options: []Option{
+				WithSYNScan(),
+				WithPorts("53", "80"),
+				WithServiceInfo(),
+				WithUDPScan(),
+			},
+
+			expectedTechniques: []string{"-sS", "-sU"},
+		},
+		{
+			description: "idle scan with repeated technique",
+
+			options: []Option{
+				WithIdleScan("192.168.0.254", 80),
+				WithIdleScan("192.168.0.253", 0),
+			},
+
+			expectedTechniques: []string{"-sI"},
+		},
+		{
+			description: "no technique",
+
+			options: []Option{
+				WithPorts("80"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), test.options...)
+			if err != nil {
+				panic(err)
+			}
+
+			if !reflect.DeepEqual(s.ScanTechniques(), test.expectedTechniques) {
+				t.Errorf("unexpected techniques, expected %s got %s", test.expectedTechniques, s.ScanTechniques())
+			}
+		})
+	}
+}
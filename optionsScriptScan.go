@@ -2,6 +2,7 @@ package nmap
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -26,7 +27,23 @@ func WithScripts(scripts ...string) Option {
 	}
 }
 
+// WithSafeScripts sets the scanner to perform a script scan using only the scripts
+// of the safe category, which are not designed to crash services, use large amounts
+// of bandwidth or exploit vulnerabilities, such as when scanning production systems.
+// Scripts given to nmap are cumulative, so combining it with WithScripts runs the
+// safe scripts along with the given ones. To only run the given scripts which are
+// safe, use an expression such as WithScripts("safe and http-*") instead.
+func WithSafeScripts() Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "--script=safe")
+	}
+}
+
 // WithScriptArguments provides arguments for scripts. If a value is the empty string, the key will be used as a flag.
+// Values starting with @ reference files, such as userdb=@/path/to/users.txt, and NewScanner returns
+// ErrInvalidOption if the referenced file does not exist.
+// Arguments given over multiple calls, or along with WithScriptArgsStruct, are merged into a single --script-args.
+// It can't be used along with WithScriptArgumentsFile.
 func WithScriptArguments(arguments map[string]string) Option {
 	var argList string
 
@@ -47,11 +64,135 @@ func WithScriptArguments(arguments map[string]string) Option {
 	argList = strings.TrimLeft(argList, ",")
 
 	return func(s *Scanner) {
-		s.args = append(s.args, fmt.Sprintf("--script-args=%s", argList))
+		s.addScriptArgs(argList)
+
+		for key, value := range arguments {
+			if path, ok := strings.CutPrefix(value, "@"); ok {
+				s.scriptArgFiles = append(s.scriptArgFiles, scriptArgFile{key: key, path: path})
+			}
+		}
+	}
+}
+
+// scriptArgFile is a script argument whose value references a file, such as userdb=@/path/to/users.txt.
+type scriptArgFile struct {
+	key  string
+	path string
+}
+
+// addScriptArgs merges the given script arguments into the --script-args of
+// the scanner, since nmap only takes the last --script-args into account.
+func (s *Scanner) addScriptArgs(argList string) {
+	for i, arg := range s.args {
+		existing, found := strings.CutPrefix(arg, "--script-args=")
+		if !found {
+			continue
+		}
+
+		if existing != "" && argList != "" {
+			argList = existing + "," + argList
+		} else {
+			argList = existing + argList
+		}
+		s.args[i] = fmt.Sprintf("--script-args=%s", argList)
+		return
 	}
+
+	s.args = append(s.args, fmt.Sprintf("--script-args=%s", argList))
+}
+
+// WithScriptArgsStruct provides arguments for scripts from the exported fields
+// of a struct (or a pointer to a struct), as prefix.key=value entries. Keys
+// default to the lowercased field names, and can be set using the nmap struct
+// tag. A tag of "-" skips the field, and the omitempty tag option skips the
+// field when it holds its zero value. Slices are formatted as tables, and
+// values containing special characters are quoted. The arguments are merged
+// with the ones given to WithScriptArguments.
+// For example, the http-form-brute script arguments can be set using:
+//
+//	type formBruteArgs struct {
+//		Path    string `nmap:"path"`
+//		PassVar string `nmap:"passvar"`
+//	}
+//
+//	WithScriptArgsStruct("http-form-brute", formBruteArgs{Path: "/login", PassVar: "pass"})
+func WithScriptArgsStruct(prefix string, v any) Option {
+	return func(s *Scanner) {
+		value := reflect.ValueOf(v)
+		for value.Kind() == reflect.Pointer && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			panic("value given to nmap.WithScriptArgsStruct() should be a struct")
+		}
+
+		var args []string
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			key := strings.ToLower(field.Name)
+			var omitEmpty bool
+			if tag, ok := field.Tag.Lookup("nmap"); ok {
+				name, options, _ := strings.Cut(tag, ",")
+				if name == "-" {
+					continue
+				}
+				if name != "" {
+					key = name
+				}
+				omitEmpty = options == "omitempty"
+			}
+
+			if omitEmpty && value.Field(i).IsZero() {
+				continue
+			}
+
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+
+			args = append(args, fmt.Sprintf("%s=%s", key, formatScriptArgValue(value.Field(i))))
+		}
+
+		s.addScriptArgs(strings.Join(args, ","))
+	}
+}
+
+// formatScriptArgValue formats a value for nmap's script arguments. Slices and
+// arrays are formatted as tables, and other values are quoted when needed.
+func formatScriptArgValue(value reflect.Value) string {
+	if stringer, ok := value.Interface().(fmt.Stringer); ok {
+		return quoteScriptArgValue(stringer.String())
+	}
+
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		elements := make([]string, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			elements = append(elements, formatScriptArgValue(value.Index(i)))
+		}
+		return "{" + strings.Join(elements, ",") + "}"
+	default:
+		return quoteScriptArgValue(fmt.Sprint(value.Interface()))
+	}
+}
+
+// quoteScriptArgValue quotes a script argument value if it contains characters
+// that have a meaning in nmap's script arguments syntax.
+func quoteScriptArgValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ",={}\"\\ \t") {
+		return value
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(value) + `"`
 }
 
 // WithScriptArgumentsFile provides arguments for scripts from a file.
+// It can't be used along with WithScriptArguments or WithScriptArgsStruct.
 func WithScriptArgumentsFile(inputFilePath string) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, fmt.Sprintf("--script-args-file=%s", inputFilePath))
@@ -59,6 +200,8 @@ func WithScriptArgumentsFile(inputFilePath string) Option {
 }
 
 // WithScriptTrace makes the scripts show all data sent and received.
+// The traced traffic is available through Run.ScriptTrace instead of
+// being reported as warnings.
 func WithScriptTrace() Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "--script-trace")
@@ -40,6 +40,17 @@ func TestScriptScan(t *testing.T) {
 				"--script=./scripts/,/etc/nmap/nse/scripts",
 			},
 		},
+		{
+			description: "safe scripts",
+
+			options: []Option{
+				WithSafeScripts(),
+			},
+
+			expectedArgs: []string{
+				"--script=safe",
+			},
+		},
 		{
 			description: "script arguments",
 
@@ -64,6 +75,21 @@ func TestScriptScan(t *testing.T) {
 				"vulns.showall",
 			},
 		},
+		{
+			description: "script arguments over multiple calls",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"user": "foo"}),
+				WithScripts("http-title"),
+				WithScriptArguments(map[string]string{"vulns.showall": ""}),
+				WithScriptArguments(map[string]string{}),
+			},
+
+			expectedArgs: []string{
+				"--script-args=user=foo,vulns.showall",
+				"--script=http-title",
+			},
+		},
 		{
 			description: "script arguments file",
 
@@ -133,3 +159,106 @@ func TestScriptScan(t *testing.T) {
 		})
 	}
 }
+
+func TestScriptArgsStruct(t *testing.T) {
+	type formBruteArgs struct {
+		Path      string `nmap:"path"`
+		Method    string `nmap:"method"`
+		PassVar   string `nmap:"passvar"`
+		OnFailure string `nmap:"onfailure,omitempty"`
+		OnSuccess string `nmap:"onsuccess,omitempty"`
+		Hostname  string `nmap:"-"`
+		Threads   int    `nmap:"threads"`
+		Verbose   bool
+		Users     []string `nmap:"users"`
+		secret    string
+	}
+
+	args := formBruteArgs{
+		Path:      "/login.php",
+		Method:    "POST",
+		PassVar:   "pass",
+		OnFailure: `Invalid "user", try again`,
+		Hostname:  "ignored",
+		Threads:   4,
+		Verbose:   true,
+		Users:     []string{"admin", "root"},
+		secret:    "ignored",
+	}
+
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedArgs []string
+	}{
+		{
+			description: "struct with prefix",
+
+			options: []Option{
+				WithScriptArgsStruct("http-form-brute", args),
+			},
+
+			expectedArgs: []string{
+				`--script-args=http-form-brute.path=/login.php,http-form-brute.method=POST,http-form-brute.passvar=pass,` +
+					`http-form-brute.onfailure="Invalid \"user\", try again",http-form-brute.threads=4,` +
+					`http-form-brute.verbose=true,http-form-brute.users={admin,root}`,
+			},
+		},
+		{
+			description: "pointer to struct without prefix",
+
+			options: []Option{
+				WithScriptArgsStruct("", &struct {
+					UserDB string `nmap:"userdb"`
+				}{UserDB: "/tmp/users.txt"}),
+			},
+
+			expectedArgs: []string{
+				"--script-args=userdb=/tmp/users.txt",
+			},
+		},
+		{
+			description: "struct merged with script arguments",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"vulns.showall": ""}),
+				WithScriptArgsStruct("", struct {
+					UserDB string `nmap:"userdb"`
+				}{UserDB: "/tmp/users.txt"}),
+			},
+
+			expectedArgs: []string{
+				"--script-args=vulns.showall,userdb=/tmp/users.txt",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), test.options...)
+			if err != nil {
+				panic(err)
+			}
+
+			if !reflect.DeepEqual(s.args, test.expectedArgs) {
+				t.Errorf("unexpected arguments, expected %s got %s", test.expectedArgs, s.args)
+			}
+		})
+	}
+}
+
+func TestScriptArgsStructPanicsOnInvalidValue(t *testing.T) {
+	expectedPanic := "value given to nmap.WithScriptArgsStruct() should be a struct"
+
+	defer func() {
+		recoveredMessage := recover()
+
+		if recoveredMessage != expectedPanic {
+			t.Errorf("expected panic message to be %q but got %q", expectedPanic, recoveredMessage)
+		}
+	}()
+
+	_, _ = NewScanner(context.TODO(), WithScriptArgsStruct("prefix", "not a struct"))
+}
@@ -10,6 +10,35 @@ func WithServiceInfo() Option {
 	}
 }
 
+// WithLightServiceInfo enables the probing of open ports to determine service and
+// version info using light probing, which is the same as using WithServiceInfo
+// along with WithVersionLight.
+func WithLightServiceInfo() Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-sV", "--version-light")
+	}
+}
+
+// WithThoroughServiceInfo enables the probing of open ports to determine service and
+// version info using every single probe, which is the same as using WithServiceInfo
+// along with WithVersionAll.
+func WithThoroughServiceInfo() Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-sV", "--version-all")
+	}
+}
+
+// WithRPCScan enables RPC grinding, which floods the open ports found to be
+// SunRPC services with RPC null commands to determine their program number
+// and version.
+// Since nmap 4.49, RPC grinding is part of version detection, and -sR is only
+// an alias for -sV, which should be preferred using WithServiceInfo.
+func WithRPCScan() Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-sR")
+	}
+}
+
 // WithVersionIntensity sets the level of intensity with which nmap should
 // probe the open ports to get version information.
 // Intensity should be a value between 0 (light) and 9 (try all probes). The
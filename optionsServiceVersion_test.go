@@ -26,6 +26,41 @@ func TestServiceDetection(t *testing.T) {
 				"-sV",
 			},
 		},
+		{
+			description: "RPC scan",
+
+			options: []Option{
+				WithRPCScan(),
+			},
+
+			expectedArgs: []string{
+				"-sR",
+			},
+		},
+		{
+			description: "light service detection",
+
+			options: []Option{
+				WithLightServiceInfo(),
+			},
+
+			expectedArgs: []string{
+				"-sV",
+				"--version-light",
+			},
+		},
+		{
+			description: "thorough service detection",
+
+			options: []Option{
+				WithThoroughServiceInfo(),
+			},
+
+			expectedArgs: []string{
+				"-sV",
+				"--version-all",
+			},
+		},
 		{
 			description: "service detection custom intensity",
 
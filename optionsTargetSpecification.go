@@ -2,13 +2,17 @@ package nmap
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
 // WithTargets sets the target of a scanner.
+// Targets are kept apart from the other arguments and are always given
+// last to nmap. Targets starting with a dash are rejected by NewScanner,
+// since nmap would interpret them as options.
 func WithTargets(targets ...string) Option {
 	return func(s *Scanner) {
-		s.args = append(s.args, targets...)
+		s.targets = append(s.targets, targets...)
 	}
 }
 
@@ -22,6 +26,16 @@ func WithTargetExclusions(targets ...string) Option {
 	}
 }
 
+// WithStrictTargetValidation makes NewScanner check that each target given to WithTargets
+// is an IP address, a CIDR, an IPv4 octet range such as 192.168.0-255.1-254, or a valid
+// hostname, and return ErrInvalidTarget listing the invalid ones otherwise. This guards
+// services which scan user-supplied targets.
+func WithStrictTargetValidation() Option {
+	return func(s *Scanner) {
+		s.strictTargets = true
+	}
+}
+
 // WithTargetInput sets the input file name to set the targets.
 func WithTargetInput(inputFileName string) Option {
 	return func(s *Scanner) {
@@ -30,6 +44,17 @@ func WithTargetInput(inputFileName string) Option {
 	}
 }
 
+// WithTargetsFromReader makes nmap read its targets from the given reader, which
+// is piped to nmap's standard input. This avoids huge command lines and temporary
+// files when targets come from a stream. Targets are separated by whitespace, like
+// in the files given to WithTargetInput. The reader is consumed by the first scan.
+func WithTargetsFromReader(reader io.Reader) Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "-iL", "-")
+		s.stdin = reader
+	}
+}
+
 // WithTargetExclusionInput sets the input file name to set the target exclusions.
 func WithTargetExclusionInput(inputFileName string) Option {
 	return func(s *Scanner) {
@@ -46,6 +71,15 @@ func WithRandomTargets(randomTargets int) Option {
 	}
 }
 
+// WithShuffleTargets shuffles the targets given to WithTargets before giving them to nmap,
+// using the given seed. Unlike nmap's --randomize-hosts, the order is reproducible:
+// the same seed and targets always result in the same order.
+func WithShuffleTargets(seed int64) Option {
+	return func(s *Scanner) {
+		s.shuffleSeed = &seed
+	}
+}
+
 // WithUnique makes each address be scanned only once.
 // The default behavior is to scan each address as many times
 // as it is specified in the target list, such as when network
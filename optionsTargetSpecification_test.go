@@ -3,7 +3,10 @@ package nmap
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestTargetSpecification(t *testing.T) {
@@ -23,8 +26,9 @@ func TestTargetSpecification(t *testing.T) {
 			},
 
 			expectedArgs: []string{
-				"0.0.0.0/24",
 				"--invalid-argument",
+				"--",
+				"0.0.0.0/24",
 			},
 		},
 		{
@@ -35,6 +39,7 @@ func TestTargetSpecification(t *testing.T) {
 			},
 
 			expectedArgs: []string{
+				"--",
 				"0.0.0.0/24",
 			},
 		},
@@ -46,6 +51,7 @@ func TestTargetSpecification(t *testing.T) {
 			},
 
 			expectedArgs: []string{
+				"--",
 				"0.0.0.0",
 				"192.168.1.1",
 			},
@@ -62,6 +68,18 @@ func TestTargetSpecification(t *testing.T) {
 				"/targets.txt",
 			},
 		},
+		{
+			description: "set targets from reader",
+
+			options: []Option{
+				WithTargetsFromReader(strings.NewReader("192.168.1.1")),
+			},
+
+			expectedArgs: []string{
+				"-iL",
+				"-",
+			},
+		},
 		{
 			description: "choose random targets",
 
@@ -118,9 +136,55 @@ func TestTargetSpecification(t *testing.T) {
 				panic(err)
 			}
 
-			if !reflect.DeepEqual(s.args, test.expectedArgs) {
-				t.Errorf("unexpected arguments, expected %s got %s", test.expectedArgs, s.args)
+			if !reflect.DeepEqual(s.Args(), test.expectedArgs) {
+				t.Errorf("unexpected arguments, expected %s got %s", test.expectedArgs, s.Args())
 			}
 		})
 	}
 }
+
+func TestRunWithTargetsFromReader(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stdin.sh"),
+		WithTargetsFromReader(strings.NewReader("192.168.1.1\n192.168.1.2 10.0.0.1\n")),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+
+	var addrs []string
+	for _, host := range result.Hosts {
+		addrs = append(addrs, host.Addresses[0].Addr)
+	}
+	assert.Equal(t, []string{"192.168.1.1", "192.168.1.2", "10.0.0.1"}, addrs)
+}
+
+func TestWithShuffleTargets(t *testing.T) {
+	targets := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}
+
+	newArgs := func(seed int64) []string {
+		s, err := NewScanner(context.TODO(), WithShuffleTargets(seed), WithTargets(targets...))
+		if err != nil {
+			panic(err)
+		}
+		return s.Args()
+	}
+
+	first := newArgs(42)
+	assert.Equal(t, first, newArgs(42), "expected the same seed to give the same order")
+	assert.NotEqual(t, append([]string{"--"}, targets...), first, "expected targets to be shuffled")
+	assert.ElementsMatch(t, append([]string{"--"}, targets...), first)
+	assert.Equal(t, "--", first[0])
+	assert.NotEqual(t, first, newArgs(7), "expected a different seed to give a different order")
+
+	s, err := NewScanner(context.TODO(), WithTargets(targets...), WithShuffleTargets(42))
+	if err != nil {
+		panic(err)
+	}
+	assert.Equal(t, first, s.buildArgs()[2:])
+	assert.Equal(t, targets, s.targets, "expected the stored targets to be left untouched")
+}
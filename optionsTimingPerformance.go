@@ -2,6 +2,8 @@ package nmap
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -25,6 +27,8 @@ const (
 )
 
 // WithTimingTemplate sets the timing template for nmap.
+// Timings outside of the TimingSlowest to TimingFastest range make
+// NewScanner return ErrInvalidOption.
 func WithTimingTemplate(timing Timing) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, fmt.Sprintf("-T%d", timing))
@@ -71,6 +75,86 @@ func WithMaxParallelism(probes int) Option {
 	}
 }
 
+// DurationUnits represents how durations are written in the arguments given to nmap.
+// These are meant to be used with the WithDurationUnits method.
+type DurationUnits int
+
+const (
+	// DurationMilliseconds writes all durations in milliseconds, such as "120000ms",
+	// which every version of nmap accepts. This is the default.
+	DurationMilliseconds DurationUnits = iota
+	// DurationCanonical writes durations in the largest unit which represents them
+	// exactly, such as "2m", "8h" or "42ms", which is easier to read.
+	DurationCanonical
+)
+
+// durationFlags are the flags whose durations are written by the options.
+var durationFlags = map[string]bool{
+	"--min-rtt-timeout":     true,
+	"--max-rtt-timeout":     true,
+	"--initial-rtt-timeout": true,
+	"--host-timeout":        true,
+	"--scan-delay":          true,
+	"--max-scan-delay":      true,
+	"--script-timeout":      true,
+	"--stats-every":         true,
+}
+
+// WithDurationUnits sets how the durations given to the options are written
+// in the arguments given to nmap. It applies to all durations regardless of
+// the order of the options. Without it, durations are written in milliseconds,
+// as they always were, so that the arguments of existing scanners don't change
+// and stay compatible with every version of nmap.
+func WithDurationUnits(units DurationUnits) Option {
+	return func(s *Scanner) {
+		s.durationUnits = units
+	}
+}
+
+// formatDurations rewrites in place the durations written in milliseconds in
+// the given arguments according to the duration units of the scanner.
+func (s *Scanner) formatDurations(args []string) {
+	if s.durationUnits != DurationCanonical {
+		return
+	}
+
+	for i := 1; i < len(args); i++ {
+		if !durationFlags[args[i-1]] {
+			continue
+		}
+
+		number, found := strings.CutSuffix(args[i], "ms")
+		if !found {
+			continue
+		}
+
+		milliseconds, err := strconv.ParseInt(number, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		args[i] = formatNmapDuration(time.Duration(milliseconds) * time.Millisecond)
+	}
+}
+
+// formatNmapDuration writes a duration in nmap's format, using the largest
+// unit which represents it exactly.
+func formatNmapDuration(duration time.Duration) string {
+	milliseconds := duration.Milliseconds()
+	if milliseconds != 0 {
+		switch {
+		case milliseconds%time.Hour.Milliseconds() == 0:
+			return fmt.Sprintf("%dh", milliseconds/time.Hour.Milliseconds())
+		case milliseconds%time.Minute.Milliseconds() == 0:
+			return fmt.Sprintf("%dm", milliseconds/time.Minute.Milliseconds())
+		case milliseconds%time.Second.Milliseconds() == 0:
+			return fmt.Sprintf("%ds", milliseconds/time.Second.Milliseconds())
+		}
+	}
+
+	return fmt.Sprintf("%dms", milliseconds)
+}
+
 // WithMinRTTTimeout sets the minimal probe round trip time.
 func WithMinRTTTimeout(roundTripTime time.Duration) Option {
 	milliseconds := roundTripTime.Round(time.Nanosecond).Nanoseconds() / 1000000
@@ -102,6 +186,8 @@ func WithInitialRTTTimeout(roundTripTime time.Duration) Option {
 }
 
 // WithMaxRetries sets the maximal number of port scan probe retransmissions.
+// Zero disables retransmissions, while negative values make NewScanner
+// return ErrInvalidOption.
 func WithMaxRetries(tries int) Option {
 	return func(s *Scanner) {
 		s.args = append(s.args, "--max-retries")
@@ -110,6 +196,9 @@ func WithMaxRetries(tries int) Option {
 }
 
 // WithHostTimeout sets the time after which nmap should give up on a target host.
+// The timeout must be at least a millisecond: since nmap interprets a zero timeout as
+// no timeout at all, NewScanner returns ErrInvalidOption for it. To scan without a
+// host timeout, which is nmap's default, omit this option.
 func WithHostTimeout(timeout time.Duration) Option {
 	milliseconds := timeout.Round(time.Nanosecond).Nanoseconds() / 1000000
 
@@ -119,7 +208,25 @@ func WithHostTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithPerHostTimeout sets the time after which nmap should give up on a target host,
+// like WithHostTimeout. Since a scan runs as a single nmap process, nmap enforces this
+// timeout itself and the scan's context can only cancel the scan as a whole. To bound
+// the time spent on groups of hosts, use Scanner.RunChunked along with WithTimeout:
+// each chunk is then given its own deadline, and a chunk which exceeds it, such as
+// when nmap hangs, is cancelled while the remaining chunks are still run.
+// Like with WithHostTimeout, the timeout must be at least a millisecond.
+func WithPerHostTimeout(timeout time.Duration) Option {
+	milliseconds := timeout.Round(time.Nanosecond).Nanoseconds() / 1000000
+
+	return func(s *Scanner) {
+		s.args = append(s.args, "--host-timeout")
+		s.args = append(s.args, fmt.Sprintf("%dms", int(milliseconds)))
+	}
+}
+
 // WithScanDelay sets the minimum time to wait between each probe sent to a host.
+// Since the delay applies to each host of a host group, delays longer than a
+// second can't be combined with WithMaxHostgroup above 64 hosts.
 func WithScanDelay(timeout time.Duration) Option {
 	milliseconds := timeout.Round(time.Nanosecond).Nanoseconds() / 1000000
 
@@ -129,6 +236,35 @@ func WithScanDelay(timeout time.Duration) Option {
 	}
 }
 
+// WithRandomizedScanDelay sets the minimum time to wait between each probe sent
+// to a host to a random delay between base and base+jitter, which is picked again
+// every time the scan is run. This spreads the load of repeated scheduled scans.
+// Since the delay is only known when running the scan, it isn't part of Args.
+// It can't be combined with WithScanDelay, and base+jitter, the longest delay it
+// can pick, is checked against WithMaxScanDelay and WithMaxHostgroup like a delay
+// given to WithScanDelay.
+func WithRandomizedScanDelay(base, jitter time.Duration) Option {
+	return func(s *Scanner) {
+		s.randomScanDelay = &randomDelay{base: base, jitter: jitter}
+	}
+}
+
+// randomDelay is a delay picked randomly between base and base+jitter.
+type randomDelay struct {
+	base   time.Duration
+	jitter time.Duration
+}
+
+// pick returns a random delay using the given random number generator,
+// which returns a number in [0,n).
+func (d randomDelay) pick(random func(n int64) int64) time.Duration {
+	if d.jitter <= 0 {
+		return d.base
+	}
+
+	return d.base + time.Duration(random(int64(d.jitter)+1))
+}
+
 // WithMaxScanDelay sets the maximum time to wait between each probe sent to a host.
 func WithMaxScanDelay(timeout time.Duration) Option {
 	milliseconds := timeout.Round(time.Nanosecond).Nanoseconds() / 1000000
@@ -154,3 +290,128 @@ func WithMaxRate(packetsPerSecond int) Option {
 		s.args = append(s.args, fmt.Sprint(packetsPerSecond))
 	}
 }
+
+// WithMinRateFloat sets the minimal number of packets sent per second,
+// which nmap accepts as a fractional number.
+// The rate should be strictly positive.
+func WithMinRateFloat(packetsPerSecond float64) Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "--min-rate")
+		s.args = append(s.args, strconv.FormatFloat(packetsPerSecond, 'f', -1, 64))
+	}
+}
+
+// WithMaxRateFloat sets the maximal number of packets sent per second,
+// which nmap accepts as a fractional number. For example, a rate of 0.5
+// sends one packet every two seconds.
+// The rate should be strictly positive.
+func WithMaxRateFloat(packetsPerSecond float64) Option {
+	return func(s *Scanner) {
+		s.args = append(s.args, "--max-rate")
+		s.args = append(s.args, strconv.FormatFloat(packetsPerSecond, 'f', -1, 64))
+	}
+}
+
+// PerformanceConfig bundles the performance knobs of a scan, so that they can
+// be set and validated together. Zero values are left to nmap's defaults.
+type PerformanceConfig struct {
+	// MinRate and MaxRate bound the number of packets sent per second.
+	MinRate float64
+	MaxRate float64
+
+	// MinParallelism and MaxParallelism bound the number of parallel probes.
+	MinParallelism int
+	MaxParallelism int
+
+	// MinHostgroup and MaxHostgroup bound the size of the groups of hosts scanned in parallel.
+	MinHostgroup int
+	MaxHostgroup int
+}
+
+// WithPerformanceProfile sets the rates, parallelism and host group sizes of the
+// given config. NewScanner returns ErrInvalidOption if any of them is negative, and
+// ErrConflictingOptions if any minimum is greater than its maximum.
+func WithPerformanceProfile(config PerformanceConfig) Option {
+	return func(s *Scanner) {
+		if config.MinRate != 0 {
+			WithMinRateFloat(config.MinRate)(s)
+		}
+		if config.MaxRate != 0 {
+			WithMaxRateFloat(config.MaxRate)(s)
+		}
+		if config.MinParallelism != 0 {
+			WithMinParallelism(config.MinParallelism)(s)
+		}
+		if config.MaxParallelism != 0 {
+			WithMaxParallelism(config.MaxParallelism)(s)
+		}
+		if config.MinHostgroup != 0 {
+			WithMinHostgroup(config.MinHostgroup)(s)
+		}
+		if config.MaxHostgroup != 0 {
+			WithMaxHostgroup(config.MaxHostgroup)(s)
+		}
+	}
+}
+
+// BuildTiming describes the components of a timing template, so that the
+// knobs set by the -T0 to -T5 presets can be tuned individually.
+// Zero values are left to nmap's defaults.
+type BuildTiming struct {
+	// MinRTTTimeout, MaxRTTTimeout and InitialRTTTimeout bound the time nmap waits
+	// for a probe response. The initial timeout must be between the minimum and maximum.
+	MinRTTTimeout     time.Duration
+	MaxRTTTimeout     time.Duration
+	InitialRTTTimeout time.Duration
+
+	// ScanDelay and MaxScanDelay bound the time to wait between each probe sent to a host.
+	ScanDelay    time.Duration
+	MaxScanDelay time.Duration
+
+	// MinParallelism and MaxParallelism bound the number of parallel probes.
+	MinParallelism int
+	MaxParallelism int
+
+	// MaxRetries is the maximal number of probe retransmissions.
+	// Use WithMaxRetries to disable retransmissions.
+	MaxRetries int
+
+	// HostTimeout is the time after which nmap gives up on a host.
+	HostTimeout time.Duration
+}
+
+// WithCustomTiming sets the timing components of the given config, as an
+// explicit alternative to WithTimingTemplate. NewScanner returns ErrInvalidOption
+// if a component is invalid, and ErrConflictingOptions if a minimum is greater
+// than its maximum or the initial RTT timeout isn't between them.
+func WithCustomTiming(timing BuildTiming) Option {
+	return func(s *Scanner) {
+		if timing.MinRTTTimeout != 0 {
+			WithMinRTTTimeout(timing.MinRTTTimeout)(s)
+		}
+		if timing.MaxRTTTimeout != 0 {
+			WithMaxRTTTimeout(timing.MaxRTTTimeout)(s)
+		}
+		if timing.InitialRTTTimeout != 0 {
+			WithInitialRTTTimeout(timing.InitialRTTTimeout)(s)
+		}
+		if timing.ScanDelay != 0 {
+			WithScanDelay(timing.ScanDelay)(s)
+		}
+		if timing.MaxScanDelay != 0 {
+			WithMaxScanDelay(timing.MaxScanDelay)(s)
+		}
+		if timing.MinParallelism != 0 {
+			WithMinParallelism(timing.MinParallelism)(s)
+		}
+		if timing.MaxParallelism != 0 {
+			WithMaxParallelism(timing.MaxParallelism)(s)
+		}
+		if timing.MaxRetries != 0 {
+			WithMaxRetries(timing.MaxRetries)(s)
+		}
+		if timing.HostTimeout != 0 {
+			WithHostTimeout(timing.HostTimeout)(s)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package nmap
 
 import (
 	"context"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
@@ -86,6 +87,64 @@ func TestTimingAndPerformance(t *testing.T) {
 				"42",
 			},
 		},
+		{
+			description: "set performance profile",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinRate:        0.5,
+					MaxRate:        100,
+					MaxParallelism: 10,
+					MinHostgroup:   16,
+					MaxHostgroup:   64,
+				}),
+			},
+
+			expectedArgs: []string{
+				"--min-rate",
+				"0.5",
+				"--max-rate",
+				"100",
+				"--max-parallelism",
+				"10",
+				"--min-hostgroup",
+				"16",
+				"--max-hostgroup",
+				"64",
+			},
+		},
+		{
+			description: "set custom timing",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					MinRTTTimeout:     100 * time.Millisecond,
+					MaxRTTTimeout:     1250 * time.Millisecond,
+					InitialRTTTimeout: 500 * time.Millisecond,
+					MaxScanDelay:      10 * time.Millisecond,
+					MaxParallelism:    8,
+					MaxRetries:        6,
+					HostTimeout:       15 * time.Minute,
+				}),
+			},
+
+			expectedArgs: []string{
+				"--min-rtt-timeout",
+				"100ms",
+				"--max-rtt-timeout",
+				"1250ms",
+				"--initial-rtt-timeout",
+				"500ms",
+				"--max-scan-delay",
+				"10ms",
+				"--max-parallelism",
+				"8",
+				"--max-retries",
+				"6",
+				"--host-timeout",
+				"900000ms",
+			},
+		},
 		{
 			description: "set min rtt-timeout",
 
@@ -182,6 +241,18 @@ func TestTimingAndPerformance(t *testing.T) {
 				"42",
 			},
 		},
+		{
+			description: "set per-host timeout",
+
+			options: []Option{
+				WithPerHostTimeout(30 * time.Second),
+			},
+
+			expectedArgs: []string{
+				"--host-timeout",
+				"30000ms",
+			},
+		},
 		{
 			description: "set max rate",
 
@@ -194,6 +265,42 @@ func TestTimingAndPerformance(t *testing.T) {
 				"42",
 			},
 		},
+		{
+			description: "set fractional min rate",
+
+			options: []Option{
+				WithMinRateFloat(0.25),
+			},
+
+			expectedArgs: []string{
+				"--min-rate",
+				"0.25",
+			},
+		},
+		{
+			description: "set fractional max rate",
+
+			options: []Option{
+				WithMaxRateFloat(0.5),
+			},
+
+			expectedArgs: []string{
+				"--max-rate",
+				"0.5",
+			},
+		},
+		{
+			description: "set whole max rate as float",
+
+			options: []Option{
+				WithMaxRateFloat(100),
+			},
+
+			expectedArgs: []string{
+				"--max-rate",
+				"100",
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -209,3 +316,149 @@ func TestTimingAndPerformance(t *testing.T) {
 		})
 	}
 }
+
+func TestWithRandomizedScanDelay(t *testing.T) {
+	tests := []struct {
+		description string
+
+		base   time.Duration
+		jitter time.Duration
+	}{
+		{
+			description: "delay with jitter",
+
+			base:   time.Second,
+			jitter: 500 * time.Millisecond,
+		},
+		{
+			description: "delay without jitter",
+
+			base: 2 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), WithRandomizedScanDelay(test.base, test.jitter))
+			if err != nil {
+				panic(err)
+			}
+			s.random = rand.New(rand.NewSource(42)).Int63n
+
+			if len(s.Args()) != 0 {
+				t.Errorf("expected the delay not to be part of the arguments, got %s", s.Args())
+			}
+
+			delays := make(map[string]bool)
+			for i := 0; i < 20; i++ {
+				args := s.buildArgs()
+				if args[0] != "--scan-delay" {
+					t.Fatalf("expected a scan delay, got %s", args)
+				}
+
+				delay, err := time.ParseDuration(args[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if delay < test.base || delay > test.base+test.jitter {
+					t.Errorf("expected delay between %s and %s, got %s", test.base, test.base+test.jitter, delay)
+				}
+				delays[args[1]] = true
+			}
+
+			if test.jitter > 0 && len(delays) < 2 {
+				t.Errorf("expected a different delay for each run, got %v", delays)
+			}
+		})
+	}
+}
+
+func TestWithDurationUnits(t *testing.T) {
+	tests := []struct {
+		description string
+
+		timeout time.Duration
+
+		expectedMilliseconds string
+		expectedCanonical    string
+	}{
+		{
+			description: "duration in hours",
+
+			timeout: 8 * time.Hour,
+
+			expectedMilliseconds: "28800000ms",
+			expectedCanonical:    "8h",
+		},
+		{
+			description: "duration in minutes",
+
+			timeout: 2 * time.Minute,
+
+			expectedMilliseconds: "120000ms",
+			expectedCanonical:    "2m",
+		},
+		{
+			description: "duration in seconds",
+
+			timeout: 90 * time.Second,
+
+			expectedMilliseconds: "90000ms",
+			expectedCanonical:    "90s",
+		},
+		{
+			description: "duration in milliseconds",
+
+			timeout: 42 * time.Millisecond,
+
+			expectedMilliseconds: "42ms",
+			expectedCanonical:    "42ms",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			for units, expected := range map[DurationUnits]string{
+				DurationMilliseconds: test.expectedMilliseconds,
+				DurationCanonical:    test.expectedCanonical,
+			} {
+				s, err := NewScanner(
+					context.TODO(),
+					WithHostTimeout(test.timeout),
+					WithScriptTimeout(test.timeout),
+					WithDurationUnits(units),
+				)
+				if err != nil {
+					panic(err)
+				}
+
+				expectedArgs := []string{"--host-timeout", expected, "--script-timeout", expected}
+				if !reflect.DeepEqual(s.Args(), expectedArgs) {
+					t.Errorf("unexpected arguments, expected %s got %s", expectedArgs, s.Args())
+				}
+
+				if !reflect.DeepEqual(s.buildArgs()[:4], expectedArgs) {
+					t.Errorf("unexpected arguments, expected %s got %s", expectedArgs, s.buildArgs())
+				}
+			}
+		})
+	}
+}
+
+func TestWithDurationUnitsKeepsOtherArguments(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithDurationUnits(DurationCanonical),
+		WithStatsEvery("1000ms"),
+		WithCustomArguments("--data-string", "60000ms"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	expectedArgs := []string{"--stats-every", "1s", "--data-string", "60000ms"}
+	if !reflect.DeepEqual(s.Args(), expectedArgs) {
+		t.Errorf("unexpected arguments, expected %s got %s", expectedArgs, s.Args())
+	}
+}
@@ -0,0 +1,89 @@
+package nmap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// targetFlags are the target specification flags which take a value,
+// and are kept when previewing targets.
+var targetFlags = []string{"-iL", "-iR", "--exclude", "--excludefile"}
+
+// PreviewTargets runs a list scan without DNS resolution using the scanner's
+// target specification, and returns the addresses that nmap would scan, in
+// order. No packet is sent to the targets. This allows auditing the scope of
+// a scan, such as the expansion of CIDR ranges and exclusions, before running it.
+// Options unrelated to the target specification, as well as callbacks and hooks,
+// are ignored. When the targets are read from a reader using WithTargetsFromReader,
+// the reader is read entirely and buffered, so that the scan still gets the targets.
+func (s *Scanner) PreviewTargets(ctx context.Context) ([]string, error) {
+	preview := &Scanner{
+		binaryPath:  s.binaryPath,
+		ctx:         ctx,
+		args:        append([]string{"-sL", "-n"}, s.targetArgs()...),
+		targets:     s.targets,
+		shuffleSeed: s.shuffleSeed,
+	}
+
+	if s.stdin != nil && readsTargetsFromStdin(preview.args) {
+		targets, err := io.ReadAll(s.stdin)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read targets: %w", err)
+		}
+		s.stdin = bytes.NewReader(targets)
+		preview.stdin = bytes.NewReader(targets)
+	}
+
+	result, _, err := preview.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, host := range result.Hosts {
+		for _, address := range host.Addresses {
+			if address.AddrType != "mac" {
+				addresses = append(addresses, address.Addr)
+				break
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// targetArgs returns the arguments of the scanner which are part of the
+// target specification.
+func (s *Scanner) targetArgs() []string {
+	var args []string
+	for i := 0; i < len(s.args); i++ {
+		switch arg := s.args[i]; arg {
+		case "-6", "--unique":
+			args = append(args, arg)
+		default:
+			for _, flag := range targetFlags {
+				if arg == flag && i+1 < len(s.args) {
+					args = append(args, arg, s.args[i+1])
+					i++
+					break
+				}
+			}
+		}
+	}
+
+	return args
+}
+
+// readsTargetsFromStdin returns whether the given arguments make nmap read
+// its targets from its standard input.
+func readsTargetsFromStdin(args []string) bool {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-iL" && args[i+1] == "-" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package nmap
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewTargets(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_list.sh"),
+		WithTargets("192.168.1.0/30"),
+		WithTargetExclusions("192.168.1.2"),
+		WithSYNScan(),
+		WithPorts("80"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing preview and not new.
+	}
+
+	targets, err := s.PreviewTargets(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.0", "192.168.1.1", "192.168.1.3"}, targets)
+
+	// The scanner itself is left untouched.
+	assert.Equal(t, []string{"-sS", "-p", "80"}, s.args[2:])
+}
+
+func TestTargetArgs(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithIPv6Scanning(),
+		WithTargetInput("/targets.txt"),
+		WithServiceInfo(),
+		WithTargetExclusionInput("/exclusions.txt"),
+		WithUnique(),
+		WithRandomTargets(10),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	expectedArgs := []string{"-6", "-iL", "/targets.txt", "--excludefile", "/exclusions.txt", "--unique", "-iR", "10"}
+	assert.Equal(t, expectedArgs, s.targetArgs())
+}
+
+func TestPreviewTargetsIgnoresCallbacks(t *testing.T) {
+	var callbacks int
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_list.sh"),
+		WithTargets("192.168.1.0/30"),
+		WithTargetExclusions("192.168.1.2"),
+		WithScriptResultCallback(func(Host, []Script) { callbacks++ }),
+		WithBeforeRun(func([]string) { callbacks++ }),
+		WithAfterRun(func(*Run, error) { callbacks++ }),
+		WithMaxHosts(1),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing preview and not new.
+	}
+
+	targets, err := s.PreviewTargets(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.0", "192.168.1.1", "192.168.1.3"}, targets)
+	assert.Zero(t, callbacks)
+}
+
+func TestPreviewTargetsFromReader(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_stdin.sh"),
+		WithTargetsFromReader(strings.NewReader("192.168.1.1\n10.0.0.1\n")),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing preview and not new.
+	}
+
+	targets, err := s.PreviewTargets(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.1", "10.0.0.1"}, targets)
+
+	// The scan still gets the targets read by the preview.
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+	assert.Len(t, result.Hosts, 2)
+}
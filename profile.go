@@ -0,0 +1,40 @@
+package nmap
+
+import "sync"
+
+var (
+	profilesMutex sync.RWMutex
+	profiles      = map[string][]Option{}
+)
+
+// RegisterProfile registers a named set of options, which can then be applied
+// to any scanner using WithProfile. This allows sharing standard scan settings,
+// such as a "standard-external-scan" profile, across services.
+// Registering a profile with an existing name replaces it.
+// It is safe to call RegisterProfile concurrently.
+func RegisterProfile(name string, options ...Option) {
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+
+	profiles[name] = append([]Option{}, options...)
+}
+
+// WithProfile applies the options of the profile registered under the given
+// name using RegisterProfile. The profile is looked up when the scanner is
+// created, and NewScanner returns ErrInvalidOption if it was never registered.
+func WithProfile(name string) Option {
+	return func(s *Scanner) {
+		profilesMutex.RLock()
+		options, found := profiles[name]
+		profilesMutex.RUnlock()
+
+		if !found {
+			s.unknownProfiles = append(s.unknownProfiles, name)
+			return
+		}
+
+		for _, option := range options {
+			option(s)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package nmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestWithProfile(t *testing.T) {
+	RegisterProfile("standard-external-scan",
+		WithSYNScan(),
+		WithMostCommonPorts(100),
+		WithTimingTemplate(TimingAggressive),
+	)
+
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedArgs []string
+		expectedErr  error
+	}{
+		{
+			description: "registered profile",
+
+			options: []Option{
+				WithProfile("standard-external-scan"),
+			},
+
+			expectedArgs: []string{
+				"-sS",
+				"--top-ports",
+				"100",
+				"-T4",
+			},
+		},
+		{
+			description: "registered profile along with other options",
+
+			options: []Option{
+				WithProfile("standard-external-scan"),
+				WithServiceInfo(),
+			},
+
+			expectedArgs: []string{
+				"-sS",
+				"--top-ports",
+				"100",
+				"-T4",
+				"-sV",
+			},
+		},
+		{
+			description: "unknown profile",
+
+			options: []Option{
+				WithProfile("does-not-exist"),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), test.options...)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(s.args, test.expectedArgs) {
+				t.Errorf("unexpected arguments, expected %s got %s", test.expectedArgs, s.args)
+			}
+		})
+	}
+}
+
+func TestRegisterProfileConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("concurrent-profile-%d", i)
+			RegisterProfile(name, WithFastMode())
+
+			s, err := NewScanner(context.TODO(), WithProfile(name))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(s.args, []string{"-F"}) {
+				t.Errorf("unexpected arguments %s", s.args)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,140 @@
+package nmap
+
+import "strconv"
+
+// HTTPArgs are the arguments of nmap's http library, which are used by all the http-* scripts,
+// along with the arguments of the httpspider library used by the crawling scripts.
+type HTTPArgs struct {
+	// UserAgent is the User-Agent header sent in requests.
+	UserAgent string
+	// Host is the Host header sent in requests, which defaults to the target's name.
+	Host string
+	// MaxPageCount is the maximal number of pages to fetch when crawling, which is
+	// an argument of the httpspider library rather than the http one.
+	MaxPageCount int
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a HTTPArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "http.useragent", a.UserAgent)
+	setScriptArg(args, "http.host", a.Host)
+	setScriptIntArg(args, "httpspider.maxpagecount", a.MaxPageCount)
+	return args
+}
+
+// HTTPBruteArgs are the arguments of the http-brute script.
+type HTTPBruteArgs struct {
+	// Path is the path of the page protected by HTTP authentication, which defaults to /.
+	Path string
+	// Method is the HTTP method used to send credentials, which defaults to GET.
+	Method string
+	// Hostname is the value of the Host header.
+	Hostname string
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a HTTPBruteArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "http-brute.path", a.Path)
+	setScriptArg(args, "http-brute.method", a.Method)
+	setScriptArg(args, "http-brute.hostname", a.Hostname)
+	return args
+}
+
+// HTTPEnumArgs are the arguments of the http-enum script.
+type HTTPEnumArgs struct {
+	// BasePath is the path prepended to the enumerated paths.
+	BasePath string
+	// Category limits the enumerated paths to a fingerprint category, such as "attacks".
+	Category string
+	// DisplayAll makes the script report all the status codes, instead of only the successful ones.
+	DisplayAll bool
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a HTTPEnumArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "http-enum.basepath", a.BasePath)
+	setScriptArg(args, "http-enum.category", a.Category)
+	setScriptFlag(args, "http-enum.displayall", a.DisplayAll)
+	return args
+}
+
+// SMBArgs are the authentication arguments of nmap's smb library, which are used by all the smb-* scripts.
+type SMBArgs struct {
+	// Username is the user to authenticate as.
+	Username string
+	// Password is the password of the user.
+	Password string
+	// Hash is the NTLM or LM hash of the user's password, to use instead of the password.
+	Hash string
+	// Domain is the domain of the user.
+	Domain string
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a SMBArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "smbusername", a.Username)
+	setScriptArg(args, "smbpassword", a.Password)
+	setScriptArg(args, "smbhash", a.Hash)
+	setScriptArg(args, "smbdomain", a.Domain)
+	return args
+}
+
+// DNSBruteArgs are the arguments of the dns-brute script.
+type DNSBruteArgs struct {
+	// Domain is the domain to brute force, which defaults to the target's domain.
+	Domain string
+	// HostList is the path of a file containing the subdomains to try.
+	HostList string
+	// Threads is the number of concurrent resolutions.
+	Threads int
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a DNSBruteArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "dns-brute.domain", a.Domain)
+	setScriptArg(args, "dns-brute.hostlist", a.HostList)
+	setScriptIntArg(args, "dns-brute.threads", a.Threads)
+	return args
+}
+
+// DNSZoneTransferArgs are the arguments of the dns-zone-transfer script.
+type DNSZoneTransferArgs struct {
+	// Domain is the domain to transfer.
+	Domain string
+	// Server is the DNS server to request the transfer from, which defaults to the target.
+	Server string
+}
+
+// ToScriptArgs returns the script arguments to give to WithScriptArguments.
+func (a DNSZoneTransferArgs) ToScriptArgs() map[string]string {
+	args := make(map[string]string)
+	setScriptArg(args, "dns-zone-transfer.domain", a.Domain)
+	setScriptArg(args, "dns-zone-transfer.server", a.Server)
+	return args
+}
+
+// setScriptArg sets the given script argument, quoted if needed, unless its value is empty.
+func setScriptArg(args map[string]string, key, value string) {
+	if value != "" {
+		args[key] = quoteScriptArgValue(value)
+	}
+}
+
+// setScriptIntArg sets the given script argument, unless its value is zero.
+func setScriptIntArg(args map[string]string, key string, value int) {
+	if value != 0 {
+		args[key] = strconv.Itoa(value)
+	}
+}
+
+// setScriptFlag sets the given script argument as a flag if enabled.
+func setScriptFlag(args map[string]string, key string, enabled bool) {
+	if enabled {
+		args[key] = ""
+	}
+}
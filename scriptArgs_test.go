@@ -0,0 +1,96 @@
+package nmap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScriptArgsBuilders(t *testing.T) {
+	tests := []struct {
+		description string
+
+		args interface{ ToScriptArgs() map[string]string }
+
+		expectedArgs map[string]string
+	}{
+		{
+			description: "http",
+
+			args: HTTPArgs{UserAgent: "Mozilla/5.0 (compatible)", Host: "example.com", MaxPageCount: 20},
+
+			expectedArgs: map[string]string{
+				"http.useragent":          `"Mozilla/5.0 (compatible)"`,
+				"http.host":               "example.com",
+				"httpspider.maxpagecount": "20",
+			},
+		},
+		{
+			description: "http-brute",
+
+			args: HTTPBruteArgs{Path: "/admin/", Method: "POST"},
+
+			expectedArgs: map[string]string{
+				"http-brute.path":   "/admin/",
+				"http-brute.method": "POST",
+			},
+		},
+		{
+			description: "http-enum",
+
+			args: HTTPEnumArgs{BasePath: "/app", Category: "attacks", DisplayAll: true},
+
+			expectedArgs: map[string]string{
+				"http-enum.basepath":   "/app",
+				"http-enum.category":   "attacks",
+				"http-enum.displayall": "",
+			},
+		},
+		{
+			description: "smb",
+
+			args: SMBArgs{Username: "admin", Password: "p,ss", Domain: "CORP"},
+
+			expectedArgs: map[string]string{
+				"smbusername": "admin",
+				"smbpassword": `"p,ss"`,
+				"smbdomain":   "CORP",
+			},
+		},
+		{
+			description: "dns-brute",
+
+			args: DNSBruteArgs{Domain: "example.com", Threads: 8},
+
+			expectedArgs: map[string]string{
+				"dns-brute.domain":  "example.com",
+				"dns-brute.threads": "8",
+			},
+		},
+		{
+			description: "dns-zone-transfer",
+
+			args: DNSZoneTransferArgs{Domain: "example.com", Server: "ns1.example.com"},
+
+			expectedArgs: map[string]string{
+				"dns-zone-transfer.domain": "example.com",
+				"dns-zone-transfer.server": "ns1.example.com",
+			},
+		},
+		{
+			description: "empty arguments",
+
+			args: HTTPBruteArgs{},
+
+			expectedArgs: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			args := test.args.ToScriptArgs()
+			if !reflect.DeepEqual(args, test.expectedArgs) {
+				t.Errorf("expected script arguments %v, got %v", test.expectedArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package nmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// hostDecoder is an io.Writer which decodes the hosts of nmap's XML output
+// as it is written, and calls onHost for each of them as soon as nmap is
+// done with it. This allows reporting results while the scan is running.
+type hostDecoder struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+}
+
+// newHostDecoder starts decoding the hosts written into the returned decoder.
+// Close must be called once the whole output was written.
+func newHostDecoder(onHost func(Host)) *hostDecoder {
+	reader, writer := io.Pipe()
+	d := &hostDecoder{
+		writer: writer,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(d.done)
+		// Keep consuming the output if it can't be decoded, so that writes never block.
+		defer io.Copy(io.Discard, reader)
+
+		decoder := xml.NewDecoder(reader)
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return
+			}
+
+			element, ok := token.(xml.StartElement)
+			if !ok || element.Name.Local != "host" {
+				continue
+			}
+
+			var host Host
+			if err := decoder.DecodeElement(&host, &element); err != nil {
+				return
+			}
+			onHost(host)
+		}
+	}()
+
+	return d
+}
+
+// Write implements the io.Writer interface.
+func (d *hostDecoder) Write(p []byte) (int, error) {
+	return d.writer.Write(p)
+}
+
+// Close stops decoding hosts, and waits for the last host to be handled.
+func (d *hostDecoder) Close() error {
+	err := d.writer.Close()
+	<-d.done
+	return err
+}
+
+// runDecoder is an io.Writer which decodes nmap's XML output into a Run as it
+// is written, so that the output never needs to be held in memory.
+type runDecoder struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+	result Run
+	err    error
+}
+
+// newRunDecoder starts decoding the output written into the returned decoder,
+// keeping at most maxHosts hosts, or all of them if maxHosts is zero.
+// Close must be called once the whole output was written.
+func newRunDecoder(maxHosts int) *runDecoder {
+	reader, writer := io.Pipe()
+	d := &runDecoder{
+		writer: writer,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(d.done)
+		// Keep consuming the output if it can't be decoded, so that writes never block.
+		defer io.Copy(io.Discard, reader)
+
+		d.err = parseReader(reader, &d.result, maxHosts)
+	}()
+
+	return d
+}
+
+// Write implements the io.Writer interface.
+func (d *runDecoder) Write(p []byte) (int, error) {
+	return d.writer.Write(p)
+}
+
+// Close signals that the whole output was written.
+func (d *runDecoder) Close() error {
+	return d.writer.Close()
+}
+
+// Result waits for the output to be decoded, and returns the decoded run
+// along with the parsing error, if any. Close must be called first.
+func (d *runDecoder) Result() (Run, error) {
+	<-d.done
+	return d.result, d.err
+}
+
+// limitHosts removes the hosts of nmap's XML output past the first max ones,
+// so that they are never unmarshalled, and returns whether any was removed.
+// Invalid output is returned as is past the first syntax error, so that
+// parsing it reports the error.
+func limitHosts(content []byte, max int) ([]byte, bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+
+	var limited []byte
+	var hosts, depth int
+	var kept int64
+tokens:
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			// Hosts are direct children of the nmaprun element.
+			if depth != 1 || element.Name.Local != "host" {
+				depth++
+				continue
+			}
+
+			hosts++
+			if hosts <= max {
+				depth++
+				continue
+			}
+
+			if err := decoder.Skip(); err != nil {
+				break tokens
+			}
+			limited = append(limited, content[kept:start]...)
+			kept = decoder.InputOffset()
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	if limited == nil {
+		return content, false
+	}
+
+	return append(limited, content[kept:]...), true
+}
+
+// limitedRun is a Run whose hosts past the first max ones are skipped while
+// decoding, so that they are never unmarshalled.
+type limitedRun struct {
+	Run
+	XMLName xml.Name     `xml:"nmaprun"`
+	Hosts   limitedHosts `xml:"host"`
+}
+
+// limitedHosts decodes at most max hosts, or all of them if max is zero.
+type limitedHosts struct {
+	hosts     []Host
+	max       int
+	truncated bool
+}
+
+// UnmarshalXML implements the xml.Unmarshaler interface, and is called for each host.
+func (h *limitedHosts) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if h.max > 0 && len(h.hosts) >= h.max {
+		h.truncated = true
+		return d.Skip()
+	}
+
+	var host Host
+	if err := d.DecodeElement(&host, &start); err != nil {
+		return err
+	}
+	h.hosts = append(h.hosts, host)
+
+	return nil
+}
+
+// StreamJSON runs the scan, and writes each host to w as a line of JSON as soon
+// as nmap is done with it, which allows feeding live frontends. Hosts are
+// filtered the same way as in the final run, which is returned once the scan
+// is over. The scan is bound to the given context instead of the scanner's.
+// Async mode and ToFile are not supported, and are ignored: the XML output is
+// only read from stdout.
+func (s *Scanner) StreamJSON(ctx context.Context, w io.Writer) (*Run, error) {
+	var writeErr error
+	encoder := json.NewEncoder(w)
+
+	stream := *s
+	stream.ctx = ctx
+	stream.doneAsync = nil
+	stream.toFile = nil
+	stream.outputFileMode = nil
+	stream.hostCallbacks = append(append([]func(Host){}, s.hostCallbacks...), func(host Host) {
+		if writeErr != nil {
+			return
+		}
+
+		partial := &Run{Hosts: []Host{host}}
+		s.applyFilters(partial)
+
+		for _, host := range partial.Hosts {
+			if writeErr = encoder.Encode(host); writeErr != nil {
+				return
+			}
+		}
+	})
+
+	result, _, err := stream.Run()
+	if err != nil {
+		return result, err
+	}
+
+	if writeErr != nil {
+		return result, fmt.Errorf("unable to write streamed hosts: %w", writeErr)
+	}
+
+	return result, nil
+}
+
+// RunUntil runs the scan, and stops it as soon as nmap is done with a host for
+// which the given predicate returns true, such as a host with an open port. Hosts
+// are filtered the same way as in the final run before being given to the predicate.
+// When the scan is stopped early, the returned run only contains the hosts which
+// were done until then, including the matching one, and the warnings: since nmap
+// is stopped before writing them, it has no statistics nor scan information.
+// Otherwise, the complete run is returned once the scan is over. The scan is bound
+// to the given context instead of the scanner's.
+// Async mode, progress streaming and ToFile are not supported, and are ignored.
+func (s *Scanner) RunUntil(ctx context.Context, predicate func(Host) bool) (*Run, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var hosts []Host
+	var matched bool
+
+	until := *s
+	until.ctx = ctx
+	until.doneAsync = nil
+	until.liveProgress = nil
+	until.liveETA = nil
+	until.toFile = nil
+	until.outputFileMode = nil
+	until.hostCallbacks = append(append([]func(Host){}, s.hostCallbacks...), func(host Host) {
+		if matched {
+			return
+		}
+
+		partial := &Run{Hosts: []Host{host}}
+		s.applyFilters(partial)
+
+		for _, host := range partial.Hosts {
+			hosts = append(hosts, host)
+			if predicate(host) {
+				matched = true
+				cancel()
+				return
+			}
+		}
+	})
+
+	result, warnings, err := until.Run()
+	if !matched {
+		return result, err
+	}
+
+	return &Run{
+		Hosts:    hosts,
+		warnings: *warnings,
+		scanID:   s.scanID,
+	}, nil
+}
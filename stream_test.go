@@ -0,0 +1,412 @@
+package nmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostDecoder(t *testing.T) {
+	rawXML, err := os.ReadFile("tests/xml/scan_scripts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hosts []Host
+	decoder := newHostDecoder(func(host Host) {
+		hosts = append(hosts, host)
+	})
+
+	// Write the output in small chunks, like nmap does while scanning.
+	for len(rawXML) > 0 {
+		size := 7
+		if len(rawXML) < size {
+			size = len(rawXML)
+		}
+		chunk := rawXML[:size]
+		rawXML = rawXML[size:]
+
+		_, err := decoder.Write(chunk)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, decoder.Close())
+
+	assert.Len(t, hosts, 3)
+	assert.Equal(t, "192.168.1.1", hosts[0].Addresses[0].Addr)
+	assert.Equal(t, "192.168.1.3", hosts[2].Addresses[0].Addr)
+	assert.Equal(t, "ssh-hostkey", hosts[2].Ports[0].Scripts[0].ID)
+}
+
+func TestHostDecoderInvalidOutput(t *testing.T) {
+	var hosts []Host
+	decoder := newHostDecoder(func(host Host) {
+		hosts = append(hosts, host)
+	})
+
+	_, err := decoder.Write([]byte(`<nmaprun><host><status state="up"/></host><host><<<`))
+	assert.NoError(t, err)
+
+	// Writes keep succeeding after invalid output.
+	_, err = decoder.Write([]byte(`</nmaprun>`))
+	assert.NoError(t, err)
+	assert.NoError(t, decoder.Close())
+
+	assert.Len(t, hosts, 1)
+}
+
+func TestStreamJSON(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedAddrs []string
+	}{
+		{
+			description: "all hosts",
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		},
+		{
+			description: "filtered hosts",
+
+			options: []Option{
+				WithFilterHost(func(host Host) bool {
+					return len(host.OpenPorts()) > 0
+				}),
+			},
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			options := append([]Option{
+				WithBinaryPath("tests/scripts/fake_nmap_delay.sh"),
+				WithCustomArguments("tests/xml/scan_scripts.xml"),
+			}, test.options...)
+
+			s, err := NewScanner(context.TODO(), options...)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			var output bytes.Buffer
+			result, err := s.StreamJSON(context.TODO(), &output)
+			assert.NoError(t, err)
+			assert.Len(t, result.Hosts, len(test.expectedAddrs))
+
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			assert.Len(t, lines, len(test.expectedAddrs))
+
+			for idx, line := range lines {
+				var host Host
+				assert.NoError(t, json.Unmarshal([]byte(line), &host))
+				assert.Equal(t, test.expectedAddrs[idx], host.Addresses[0].Addr)
+			}
+		})
+	}
+}
+
+func TestLimitHosts(t *testing.T) {
+	rawXML, err := os.ReadFile("tests/xml/scan_scripts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+
+		max int
+
+		expectedAddrs     []string
+		expectedTruncated bool
+	}{
+		{
+			description: "below the limit",
+
+			max: 5,
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		},
+		{
+			description: "at the limit",
+
+			max: 3,
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		},
+		{
+			description: "above the limit",
+
+			max: 1,
+
+			expectedAddrs:     []string{"192.168.1.1"},
+			expectedTruncated: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var result Run
+			assert.NoError(t, parse(rawXML, &result, test.max))
+			assert.Equal(t, test.expectedTruncated, result.Truncated())
+
+			var addrs []string
+			for _, host := range result.Hosts {
+				addrs = append(addrs, host.Addresses[0].Addr)
+			}
+			assert.Equal(t, test.expectedAddrs, addrs)
+
+			// The rest of the run is still parsed.
+			assert.Equal(t, 3, result.Stats.Hosts.Total)
+
+			// The hosts left out aren't kept in the raw XML either.
+			if test.expectedTruncated {
+				assert.Less(t, len(result.rawXML), len(rawXML))
+			} else {
+				assert.Equal(t, rawXML, result.rawXML)
+			}
+
+			var reparsed Run
+			assert.NoError(t, Parse(result.rawXML, &reparsed))
+			assert.Equal(t, result.Hosts, reparsed.Hosts)
+		})
+	}
+}
+
+func TestParseReaderWithMaxHosts(t *testing.T) {
+	// The hosts past the limit are invalid, so that parsing fails if they are unmarshalled.
+	content := `<nmaprun scanner="nmap">` +
+		`<host starttime="1201479046"><address addr="192.168.1.1" addrtype="ipv4"/></host>` +
+		`<host starttime="invalid"><address addr="192.168.1.2" addrtype="ipv4"/></host>` +
+		`<host starttime="invalid"><address addr="192.168.1.3" addrtype="ipv4"/></host>` +
+		`<runstats><hosts up="3" down="0" total="3"/></runstats>` +
+		`</nmaprun>`
+
+	var unlimited Run
+	assert.Error(t, parseReader(strings.NewReader(content), &unlimited, 0))
+
+	var result Run
+	assert.NoError(t, parseReader(strings.NewReader(content), &result, 1))
+	assert.True(t, result.Truncated())
+	assert.Len(t, result.Hosts, 1)
+	assert.Equal(t, "192.168.1.1", result.Hosts[0].Addresses[0].Addr)
+	assert.Equal(t, 3, result.Stats.Hosts.Total)
+}
+
+func TestRunWithMaxHosts(t *testing.T) {
+	var callbackHosts int
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap_delay.sh"),
+		WithCustomArguments("tests/xml/scan_scripts.xml"),
+		WithMaxHosts(2),
+		WithScriptResultCallback(func(Host, []Script) {
+			callbackHosts++
+		}),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	result, _, err := s.Run()
+	assert.NoError(t, err)
+	assert.True(t, result.Truncated())
+	assert.Len(t, result.Hosts, 2)
+	assert.Equal(t, 1, callbackHosts)
+}
+
+func TestParseReader(t *testing.T) {
+	for _, fixture := range []string{
+		"tests/xml/scan_base.xml",
+		"tests/xml/scan_scripts.xml",
+		"tests/xml/scan_tcp_udp.xml",
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			content, err := os.ReadFile(fixture)
+			if err != nil {
+				panic(err)
+			}
+
+			var expected, result Run
+			assert.NoError(t, Parse(content, &expected))
+			assert.NoError(t, ParseReader(bytes.NewReader(content), &result))
+
+			expected.rawXML = nil
+			assert.Equal(t, expected, result)
+		})
+	}
+}
+
+func TestParseReaderInvalidOutput(t *testing.T) {
+	var result Run
+	err := ParseReader(strings.NewReader(`<nmaprun scanner="nmap"><host><status state="up"/></ho`), &result)
+
+	var parseErr *ErrParse
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, int64(54), parseErr.Offset)
+		assert.Empty(t, parseErr.Snippet)
+	}
+}
+
+func TestRunWithLowMemoryParse(t *testing.T) {
+	// Build a large output by repeating the hosts of a sample scan.
+	content, err := os.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		panic(err)
+	}
+	start := bytes.Index(content, []byte("<host "))
+	end := bytes.Index(content, []byte("<runstats>"))
+	large := append([]byte{}, content[:start]...)
+	for i := 0; i < 500; i++ {
+		large = append(large, content[start:end]...)
+	}
+	large = append(large, content[end:]...)
+
+	fixture := filepath.Join(t.TempDir(), "scan_large.xml")
+	if err := os.WriteFile(fixture, large, 0644); err != nil {
+		panic(err)
+	}
+
+	run := func(options ...Option) *Run {
+		options = append(options, WithBinaryPath("tests/scripts/fake_nmap.sh"), WithCustomArguments(fixture))
+		s, err := NewScanner(context.TODO(), options...)
+		if err != nil {
+			panic(err) // this is never supposed to err, as we are testing run and not new.
+		}
+
+		result, _, err := s.Run()
+		assert.NoError(t, err)
+		return result
+	}
+
+	expected := run()
+	result := run(WithLowMemoryParse())
+
+	assert.GreaterOrEqual(t, len(expected.Hosts), 500)
+	assert.Equal(t, expected.Hosts, result.Hosts)
+	assert.Equal(t, expected.Stats, result.Stats)
+	assert.Equal(t, expected.TaskProgress, result.TaskProgress)
+	assert.NotEmpty(t, expected.rawXML)
+	assert.Empty(t, result.rawXML)
+
+	limited := run(WithLowMemoryParse(), WithMaxHosts(3))
+	assert.Len(t, limited.Hosts, 3)
+	assert.True(t, limited.Truncated())
+	assert.Equal(t, expected.Hosts[:3], limited.Hosts)
+}
+
+func TestRunUntil(t *testing.T) {
+	tests := []struct {
+		description string
+
+		binaryPath string
+		args       []string
+		predicate  func(Host) bool
+
+		expectedAddrs []string
+	}{
+		{
+			description: "stops on first host with an open port",
+
+			binaryPath: "tests/scripts/fake_nmap_hang.sh",
+			args:       []string{"tests/xml/scan_scripts.xml", "19"},
+			predicate: func(host Host) bool {
+				return len(host.OpenPorts()) > 0
+			},
+
+			expectedAddrs: []string{"192.168.1.1"},
+		},
+		{
+			description: "stops on a later host",
+
+			binaryPath: "tests/scripts/fake_nmap_delay.sh",
+			args:       []string{"tests/xml/scan_scripts.xml"},
+			predicate: func(host Host) bool {
+				return host.Addresses[0].Addr == "192.168.1.2"
+			},
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.2"},
+		},
+		{
+			description: "runs the whole scan without matching host",
+
+			binaryPath: "tests/scripts/fake_nmap_delay.sh",
+			args:       []string{"tests/xml/scan_scripts.xml"},
+			predicate: func(host Host) bool {
+				return false
+			},
+
+			expectedAddrs: []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(
+				context.TODO(),
+				WithBinaryPath(test.binaryPath),
+				WithCustomArguments(test.args...),
+			)
+			if err != nil {
+				panic(err) // this is never supposed to err, as we are testing run and not new.
+			}
+
+			type runResult struct {
+				result *Run
+				err    error
+			}
+			done := make(chan runResult, 1)
+			go func() {
+				result, err := s.RunUntil(context.TODO(), test.predicate)
+				done <- runResult{result, err}
+			}()
+
+			var run runResult
+			select {
+			case run = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected the scan to stop once a host matched")
+			}
+
+			assert.NoError(t, run.err)
+
+			var addrs []string
+			for _, host := range run.result.Hosts {
+				addrs = append(addrs, host.Addresses[0].Addr)
+			}
+			assert.Equal(t, test.expectedAddrs, addrs)
+		})
+	}
+}
+
+func TestRunUntilIgnoresProgress(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml"),
+	)
+	if err != nil {
+		panic(err) // this is never supposed to err, as we are testing run and not new.
+	}
+
+	progress := make(chan float32, 100)
+	_, err = s.Progress(progress).RunUntil(context.TODO(), func(Host) bool { return false })
+	assert.NoError(t, err)
+
+	// The progress channel is left to the scanner's own scans, so it is never closed.
+	select {
+	case _, ok := <-progress:
+		t.Errorf("expected no progress, got an update or a closed channel (open: %t)", ok)
+	default:
+	}
+}
@@ -0,0 +1,13 @@
+#!/bin/bash
+
+# Appends the given XML file to the file given to -oX, like nmap does with --append-output.
+while [ $# -gt 0 ]; do
+  case "$1" in
+    -oX) shift; output=$1 ;;
+    --append-output) ;;
+    *) input=$1 ;;
+  esac
+  shift
+done
+
+cat "$input" >> "$output"
@@ -0,0 +1,9 @@
+#!/bin/bash
+
+# Writes a large and truncated XML output before failing, like nmap crashing mid-scan.
+echo '<?xml version="1.0" encoding="UTF-8"?>'
+echo '<nmaprun scanner="nmap" args="nmap" start="1684341000" version="7.93" xmloutputversion="1.05">'
+for i in $(seq 1 3000); do
+  echo "<host><status state=\"up\" reason=\"arp-response\"/><address addr=\"10.0.$((i / 256)).$((i % 256))\" addrtype=\"ipv4\"/></host>"
+done
+exit 1
@@ -0,0 +1,4 @@
+#!/bin/bash
+
+# Exits with the given exit code, like nmap being stopped by a signal.
+exit $1
@@ -0,0 +1,5 @@
+#!/bin/bash
+
+# Writes the given file to stderr and aborts, like nmap does on fatal errors.
+cat $1 >&2
+exit 1
@@ -0,0 +1,16 @@
+#!/bin/bash
+
+# Fails until it was run the given number of times, counting runs in the given
+# state file, and then prints the given XML.
+state=$1
+failures=$2
+input=$3
+
+runs=$(cat "$state" 2>/dev/null || echo 0)
+echo $((runs + 1)) > "$state"
+if [ "$runs" -lt "$failures" ]; then
+  echo "Temporary failure" >&2
+  exit 1
+fi
+
+cat "$input"
@@ -0,0 +1,6 @@
+#!/bin/bash
+
+# Prints the given number of lines of the given XML, and then hangs like a
+# long running scan would.
+head -n $2 $1
+exec sleep 10
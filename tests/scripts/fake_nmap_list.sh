@@ -0,0 +1,10 @@
+#!/bin/bash
+
+# Outputs a list scan, and fails unless it is called with exactly the arguments of a list scan preview.
+expected="-sL -n --exclude 192.168.1.2 -oX - -- 192.168.1.0/30"
+if [ "$*" != "$expected" ]; then
+  echo "unexpected arguments: $*" >&2
+  exit 1
+fi
+
+cat tests/xml/scan_list.xml
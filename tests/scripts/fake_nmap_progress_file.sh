@@ -0,0 +1,20 @@
+#!/bin/bash
+
+# Prints the normal output given as first argument line by line, and writes
+# the XML given as second argument to the file given to -oX.
+normal=$1
+input=$2
+while [ $# -gt 0 ]; do
+  if [ "$1" == "-oX" ]; then
+    output=$2
+  fi
+  shift
+done
+
+while IFS= read -r line
+do
+  echo "$line"
+  sleep 0.1
+done < "$normal"
+
+cat "$input" > "$output"
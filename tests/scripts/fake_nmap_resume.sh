@@ -0,0 +1,18 @@
+#!/bin/bash
+
+# Resumes a scan like nmap does, appending the results of the resumed scan to the XML
+# output of the aborted one, which is read from the log. Fails unless it is called
+# with --resume and nothing else, which is the only way nmap accepts it.
+if [ $# -ne 2 ] || [ "$1" != "--resume" ]; then
+  echo "unexpected arguments: $*" >&2
+  exit 1
+fi
+
+output=$(head -n 1 "$2" | sed -n 's/.* -oX \([^ ]*\) .*/\1/p')
+if [ -z "$output" ]; then
+  echo "no XML output in the log of the aborted scan" >&2
+  exit 1
+fi
+
+echo "Continuing the scan of $2"
+cat tests/xml/scan_resume_next.xml >> "$output"
@@ -0,0 +1,5 @@
+#!/bin/bash
+
+# Kills itself with the given signal, like nmap being stopped by a signal.
+kill -s $1 $$
+sleep 1
@@ -0,0 +1,4 @@
+#!/bin/bash
+
+sleep $1
+cat $2
@@ -0,0 +1,4 @@
+#!/bin/bash
+
+cat $1
+cat $2 >&2
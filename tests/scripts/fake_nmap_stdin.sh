@@ -0,0 +1,20 @@
+#!/bin/bash
+# Prints a scan result with one up host for each target read from stdin, and fails unless called with -iL -.
+
+if [[ " $* " != *" -iL - "* ]]; then
+  echo "expected targets to be read from stdin: $*" >&2
+  exit 1
+fi
+
+count=0
+hosts=""
+for target in $(cat); do
+  hosts="$hosts<host><status state=\"up\" reason=\"syn-ack\"/><address addr=\"$target\" addrtype=\"ipv4\"/></host>"
+  (( count++ ))
+done
+
+echo '<?xml version="1.0" ?>'
+echo "<nmaprun scanner=\"fake_nmap\" args=\"nmap $*\" start=\"1201479002\">"
+echo "$hosts"
+echo "<runstats><finished time=\"1201481569\" elapsed=\"1.50\"/><hosts up=\"$count\" down=\"0\" total=\"$count\"/></runstats>"
+echo '</nmaprun>'
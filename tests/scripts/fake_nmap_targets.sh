@@ -0,0 +1,23 @@
+#!/bin/bash
+# Prints a scan result with one up host for each target given after the XML output directive.
+
+while [ "$#" -gt 0 ] && [ "$1" != "-oX" ]; do
+  shift
+done
+shift 2
+
+count=0
+hosts=""
+for target in "$@"; do
+  if [ "$target" = "--" ]; then
+    continue
+  fi
+  hosts="$hosts<host><status state=\"up\" reason=\"syn-ack\"/><address addr=\"$target\" addrtype=\"ipv4\"/></host>"
+  (( count++ ))
+done
+
+echo '<?xml version="1.0" ?>'
+echo "<nmaprun scanner=\"fake_nmap\" args=\"nmap $*\" start=\"1201479002\">"
+echo "$hosts"
+echo "<runstats><finished time=\"1201481569\" elapsed=\"1.50\"/><hosts up=\"$count\" down=\"0\" total=\"$count\"/></runstats>"
+echo '</nmaprun>'
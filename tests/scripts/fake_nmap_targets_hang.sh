@@ -0,0 +1,10 @@
+#!/bin/bash
+# Hangs when scanning 192.168.0.2, and otherwise prints a scan result with one up host for each target.
+
+for target in "$@"; do
+  if [ "$target" = "192.168.0.2" ]; then
+    exec sleep 10
+  fi
+done
+
+exec "$(dirname "$0")/fake_nmap_targets.sh" "$@"
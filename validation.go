@@ -0,0 +1,595 @@
+package nmap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxHostgroupScanDelay is the longest scan delay that can be used along with
+	// host groups larger than maxDelayedHostgroup.
+	maxHostgroupScanDelay = time.Second
+	// maxDelayedHostgroup is the largest host group that can be used along with
+	// scan delays longer than maxHostgroupScanDelay.
+	maxDelayedHostgroup = 64
+)
+
+// discoveryProbeFlags are the prefixes of the host discovery probe flags.
+var discoveryProbeFlags = []string{"-PS", "-PA", "-PU", "-PY", "-PE", "-PP", "-PM", "-PO"}
+
+var (
+	// macPrefixRegex matches MAC address prefixes and full MAC addresses,
+	// with or without separators, such as 0020F2 or 01:02:03:04:05:06.
+	macPrefixRegex = regexp.MustCompile(`^[0-9A-Fa-f]{2}([:-]?[0-9A-Fa-f]{2}){0,5}$`)
+
+	// macVendorRegex matches vendor names, such as Apple or Cisco.
+	macVendorRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+	// timingTemplateRegex matches timing template arguments, such as -T4.
+	timingTemplateRegex = regexp.MustCompile(`^-T(-?\d+)$`)
+)
+
+// validate checks the scanner's options for combinations that nmap
+// either rejects or silently ignores.
+func (s *Scanner) validate() error {
+	return errors.Join(
+		s.checkTargets(),
+		s.checkHostDiscoveryConflict(),
+		s.checkLinkLocalInterface(),
+		s.checkSpoofMAC(),
+		s.checkMaxRetries(),
+		s.checkHostTimeout(),
+		s.checkRates(),
+		s.checkBounds("--min-parallelism", "--max-parallelism"),
+		s.checkBounds("--min-hostgroup", "--max-hostgroup"),
+		s.checkDurationBounds("--min-rtt-timeout", "--initial-rtt-timeout", "--max-rtt-timeout"),
+		s.checkDurationBounds("--scan-delay", "--max-scan-delay"),
+		s.checkResumeFile(),
+		s.checkTimingTemplate(),
+		s.checkScanDelayHostgroup(),
+		s.checkRandomizedScanDelay(),
+		s.checkScriptArgsConflict(),
+		s.checkProfiles(),
+		s.checkTargetSyntax(),
+		s.checkScriptArgFiles(),
+		s.checkPortlessScan(),
+		s.checkOutputFileMode(),
+	)
+}
+
+// checkTargets makes sure that no target can be interpreted as an option by
+// nmap, which would allow injecting arguments through user-supplied targets.
+func (s *Scanner) checkTargets() error {
+	for _, target := range s.targets {
+		if strings.HasPrefix(target, "-") {
+			return fmt.Errorf("%w: %q looks like an option", ErrInvalidTarget, target)
+		}
+	}
+
+	return nil
+}
+
+// checkTargetSyntax makes sure that all targets are IP addresses, CIDRs,
+// IPv4 octet ranges or hostnames when WithStrictTargetValidation is used.
+func (s *Scanner) checkTargetSyntax() error {
+	if !s.strictTargets {
+		return nil
+	}
+
+	var invalid []string
+	for _, target := range s.targets {
+		if !isValidTarget(target) {
+			invalid = append(invalid, target)
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("%w: %q", ErrInvalidTarget, invalid)
+	}
+
+	return nil
+}
+
+// isValidTarget returns whether the target follows nmap's target syntax, which
+// is an IP address, an IPv4 octet range such as 192.168.0-255.1,3 or a hostname,
+// optionally followed by a CIDR prefix length such as /24.
+func isValidTarget(target string) bool {
+	address, prefix, hasPrefix := strings.Cut(target, "/")
+
+	// IPv6 targets may have a zone index, such as fe80::1%eth0.
+	ip := net.ParseIP(address)
+	if ip == nil {
+		if host, zone, found := strings.Cut(address, "%"); found && zone != "" {
+			if zoned := net.ParseIP(host); zoned != nil && zoned.To4() == nil {
+				ip = zoned
+			}
+		}
+	}
+
+	if hasPrefix {
+		maxBits := 32
+		if ip != nil && ip.To4() == nil {
+			maxBits = 128
+		}
+
+		bits, err := strconv.Atoi(prefix)
+		if err != nil || bits < 0 || bits > maxBits {
+			return false
+		}
+	}
+
+	return ip != nil || isOctetRange(address) || isHostname(address)
+}
+
+// octetRangeRegex matches the specification of an IPv4 octet in nmap's target
+// syntax, which is a comma-separated list of numbers and ranges, or a wildcard.
+var octetRangeRegex = regexp.MustCompile(`^(\*|(\d*-\d*|\d+)(,(\d*-\d*|\d+))*)$`)
+
+// isOctetRange returns whether the address is an IPv4 address using octet
+// ranges, such as 192.168.0-255.1-254 or 10.0.*.1,3.
+func isOctetRange(address string) bool {
+	octets := strings.Split(address, ".")
+	if len(octets) != 4 {
+		return false
+	}
+
+	for _, octet := range octets {
+		if !octetRangeRegex.MatchString(octet) {
+			return false
+		}
+
+		for _, bound := range strings.FieldsFunc(octet, func(r rune) bool { return r == ',' || r == '-' || r == '*' }) {
+			if value, err := strconv.Atoi(bound); err != nil || value > 255 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// hostnameLabelRegex matches a label of a hostname.
+var hostnameLabelRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// isHostname returns whether the address is a valid hostname, such as scanme.nmap.org.
+func isHostname(address string) bool {
+	address = strings.TrimSuffix(address, ".")
+	if address == "" || len(address) > 253 {
+		return false
+	}
+
+	labels := strings.Split(address, ".")
+	for _, label := range labels {
+		if !hostnameLabelRegex.MatchString(label) {
+			return false
+		}
+	}
+
+	// A hostname made of numbers only would be a malformed IPv4 address.
+	_, err := strconv.Atoi(labels[len(labels)-1])
+	return err != nil
+}
+
+// checkHostDiscoveryConflict makes sure that host discovery probes are not
+// combined with WithSkipHostDiscovery, since -Pn disables host discovery
+// entirely and makes the probes meaningless.
+func (s *Scanner) checkHostDiscoveryConflict() error {
+	if !s.hasArg("-Pn") {
+		return nil
+	}
+
+	for _, arg := range s.args {
+		for _, flag := range discoveryProbeFlags {
+			if strings.HasPrefix(arg, flag) {
+				return fmt.Errorf("%w: host discovery probe %s can't be used along with -Pn", ErrConflictingOptions, arg)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkLinkLocalInterface makes sure that an interface is specified when
+// scanning IPv6 link-local targets, since nmap can't pick one by itself.
+// Targets that carry a zone index, such as fe80::1%eth0, already specify it.
+func (s *Scanner) checkLinkLocalInterface() error {
+	if s.hasArg("-e") {
+		return nil
+	}
+
+	for _, target := range s.targets {
+		if strings.Contains(target, "%") {
+			continue
+		}
+
+		address, _, _ := strings.Cut(target, "/")
+		ip := net.ParseIP(address)
+		if ip != nil && ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			return fmt.Errorf("%w: link-local target %s requires WithInterface", ErrInterfaceRequired, target)
+		}
+	}
+
+	return nil
+}
+
+// checkSpoofMAC makes sure that the argument given to WithSpoofMAC is either
+// 0, a MAC address prefix, a full MAC address or a vendor name.
+func (s *Scanner) checkSpoofMAC() error {
+	argument, ok := s.argValue("--spoof-mac")
+	if !ok {
+		return nil
+	}
+
+	if argument == "0" || macPrefixRegex.MatchString(argument) || macVendorRegex.MatchString(argument) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q is not a valid --spoof-mac argument", ErrInvalidOption, argument)
+}
+
+// checkMaxRetries makes sure that the number of retransmissions given to
+// WithMaxRetries is not negative. Zero is valid and disables retransmissions.
+func (s *Scanner) checkMaxRetries() error {
+	value, ok := s.argValue("--max-retries")
+	if !ok {
+		return nil
+	}
+
+	tries, err := strconv.Atoi(value)
+	if err != nil || tries < 0 {
+		return fmt.Errorf("%w: --max-retries should be a positive number or zero, got %s", ErrInvalidOption, value)
+	}
+
+	return nil
+}
+
+// checkHostTimeout makes sure that the host timeout is strictly positive, since
+// nmap interprets a zero timeout as no timeout at all, which is rarely intended.
+func (s *Scanner) checkHostTimeout() error {
+	value, ok := s.argValue("--host-timeout")
+	if !ok {
+		return nil
+	}
+
+	timeout, err := parseNmapDuration(value)
+	if err != nil || timeout <= 0 {
+		return fmt.Errorf("%w: --host-timeout should be strictly positive, got %s; omit it to scan without a host timeout", ErrInvalidOption, value)
+	}
+
+	return nil
+}
+
+// checkRates makes sure that the packet rates are strictly positive, and
+// that the minimal rate does not exceed the maximal rate.
+func (s *Scanner) checkRates() error {
+	rates := make(map[string]float64)
+	for _, flag := range []string{"--min-rate", "--max-rate"} {
+		value, ok := s.argValue(flag)
+		if !ok {
+			continue
+		}
+
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("%w: %s should be strictly positive, got %s", ErrInvalidOption, flag, value)
+		}
+		rates[flag] = rate
+	}
+
+	minRate, hasMin := rates["--min-rate"]
+	maxRate, hasMax := rates["--max-rate"]
+	if hasMin && hasMax && minRate > maxRate {
+		return fmt.Errorf("%w: --min-rate %g is greater than --max-rate %g", ErrConflictingOptions, minRate, maxRate)
+	}
+
+	return nil
+}
+
+// checkBounds makes sure that the values of the given minimum and maximum
+// flags are strictly positive integers, and that the minimum isn't greater
+// than the maximum.
+func (s *Scanner) checkBounds(minFlag, maxFlag string) error {
+	values := make(map[string]int)
+	for _, flag := range []string{minFlag, maxFlag} {
+		value, ok := s.argValue(flag)
+		if !ok {
+			continue
+		}
+
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return fmt.Errorf("%w: %s should be strictly positive, got %s", ErrInvalidOption, flag, value)
+		}
+		values[flag] = number
+	}
+
+	minValue, hasMin := values[minFlag]
+	maxValue, hasMax := values[maxFlag]
+	if hasMin && hasMax && minValue > maxValue {
+		return fmt.Errorf("%w: %s %d is greater than %s %d", ErrConflictingOptions, minFlag, minValue, maxFlag, maxValue)
+	}
+
+	return nil
+}
+
+// checkDurationBounds makes sure that the values of the given duration flags
+// are positive, and in increasing order, such as a minimum and a maximum.
+func (s *Scanner) checkDurationBounds(flags ...string) error {
+	var previousFlag string
+	var previous time.Duration
+	for _, flag := range flags {
+		value, ok := s.argValue(flag)
+		if !ok {
+			continue
+		}
+
+		duration, err := parseNmapDuration(value)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("%w: %s should be a positive duration, got %s", ErrInvalidOption, flag, value)
+		}
+
+		if previousFlag != "" && duration < previous {
+			return fmt.Errorf("%w: %s %s is greater than %s %s", ErrConflictingOptions, previousFlag, previous, flag, duration)
+		}
+		previousFlag, previous = flag, duration
+	}
+
+	return nil
+}
+
+// resumeFileHeader is the header of the normal and grepable nmap outputs,
+// which are the only ones nmap can resume a scan from.
+const resumeFileHeader = "# Nmap "
+
+// checkResumeFile makes sure that the file given to WithResumePreviousScan
+// exists and is a normal or grepable nmap output.
+func (s *Scanner) checkResumeFile() error {
+	filePath, ok := s.argValue("--resume")
+	if !ok {
+		return nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("%w: unable to open resume file: %w", ErrInvalidOption, err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(resumeFileHeader))
+	if _, err := io.ReadFull(file, header); err != nil || string(header) != resumeFileHeader {
+		return fmt.Errorf("%w: resume file %s is not a normal or grepable nmap output", ErrInvalidOption, filePath)
+	}
+
+	return nil
+}
+
+// checkTimingTemplate makes sure that the timing template given to
+// WithTimingTemplate is one of the templates supported by nmap.
+func (s *Scanner) checkTimingTemplate() error {
+	for _, arg := range s.args {
+		match := timingTemplateRegex.FindStringSubmatch(arg)
+		if match == nil {
+			continue
+		}
+
+		timing, err := strconv.Atoi(match[1])
+		if err != nil || timing < int(TimingSlowest) || timing > int(TimingFastest) {
+			return fmt.Errorf("%w: timing template should be between %d and %d, got %s", ErrInvalidOption, TimingSlowest, TimingFastest, match[1])
+		}
+	}
+
+	return nil
+}
+
+// checkScanDelayHostgroup makes sure that long scan delays are not combined
+// with large host groups, since the delay applies to every probe sent to each
+// host of the group, which can make the scan hang for hours.
+func (s *Scanner) checkScanDelayHostgroup() error {
+	delayValue, hasDelay := s.argValue("--scan-delay")
+	_, hasGroup := s.argValue("--max-hostgroup")
+	if !hasDelay || !hasGroup {
+		return nil
+	}
+
+	delay, err := parseNmapDuration(delayValue)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --scan-delay: %w", ErrInvalidOption, err)
+	}
+
+	return s.checkDelayedHostgroup(delay)
+}
+
+// checkDelayedHostgroup makes sure that the given scan delay isn't longer than
+// maxHostgroupScanDelay when host groups are larger than maxDelayedHostgroup.
+func (s *Scanner) checkDelayedHostgroup(delay time.Duration) error {
+	groupValue, hasGroup := s.argValue("--max-hostgroup")
+	if !hasGroup {
+		return nil
+	}
+
+	group, err := strconv.Atoi(groupValue)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --max-hostgroup: %w", ErrInvalidOption, err)
+	}
+
+	if delay > maxHostgroupScanDelay && group > maxDelayedHostgroup {
+		return fmt.Errorf("%w: a scan delay of %s with host groups of %d hosts can make the scan hang, reduce the scan delay to %s or the host group to %d hosts",
+			ErrConflictingOptions, delay, group, maxHostgroupScanDelay, maxDelayedHostgroup)
+	}
+
+	return nil
+}
+
+// checkRandomizedScanDelay makes sure that WithRandomizedScanDelay isn't combined
+// with another scan delay, and that the longest delay it can pick passes the same
+// checks as a delay given using WithScanDelay, since it is only picked when running.
+func (s *Scanner) checkRandomizedScanDelay() error {
+	if s.randomScanDelay == nil {
+		return nil
+	}
+
+	if s.hasFlag("--scan-delay") {
+		return fmt.Errorf("%w: a randomized scan delay can't be combined with --scan-delay", ErrConflictingOptions)
+	}
+
+	base, jitter := s.randomScanDelay.base, s.randomScanDelay.jitter
+	if base < 0 || jitter < 0 {
+		return fmt.Errorf("%w: randomized scan delay should be positive, got %s with a jitter of %s", ErrInvalidOption, base, jitter)
+	}
+
+	longest := base + jitter
+	if value, ok := s.argValue("--max-scan-delay"); ok {
+		// Invalid maximal delays are reported by checkDurationBounds.
+		maxDelay, err := parseNmapDuration(value)
+		if err == nil && longest > maxDelay {
+			return fmt.Errorf("%w: --scan-delay of up to %s is greater than --max-scan-delay %s", ErrConflictingOptions, longest, maxDelay)
+		}
+	}
+
+	return s.checkDelayedHostgroup(longest)
+}
+
+// checkScriptArgsConflict makes sure that script arguments are not given both
+// inline and from a file, since nmap refuses to start when both are set.
+func (s *Scanner) checkScriptArgsConflict() error {
+	if s.hasFlag("--script-args") && s.hasFlag("--script-args-file") {
+		return fmt.Errorf("%w: script arguments can't be given both inline and from a file", ErrConflictingOptions)
+	}
+
+	return nil
+}
+
+// checkPortlessScan makes sure that ports are not given along with scan types
+// which don't scan ports. A ping scan doesn't scan any port, and an IP protocol
+// scan interprets the port specification as IP protocol numbers.
+func (s *Scanner) checkPortlessScan() error {
+	ports, ok := s.argValue("-p")
+	if !ok {
+		return nil
+	}
+
+	if s.hasArg("-sn") {
+		return fmt.Errorf("%w: ports can't be specified along with -sn, which doesn't scan ports", ErrConflictingOptions)
+	}
+
+	if !s.hasArg("-sO") {
+		return nil
+	}
+
+	for _, entry := range strings.Split(ports, ",") {
+		if qualifier, _, found := strings.Cut(entry, ":"); found && isPortProtocol(qualifier) {
+			return fmt.Errorf("%w: -sO scans IP protocol numbers, which can't be qualified with a port protocol such as %s", ErrConflictingOptions, entry)
+		}
+
+		for _, bound := range strings.Split(entry, "-") {
+			number, err := strconv.Atoi(bound)
+			if err == nil && number > 255 {
+				return fmt.Errorf("%w: -sO scans IP protocol numbers from 0 to 255, so %s isn't a valid protocol", ErrConflictingOptions, entry)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkOutputFileMode makes sure that the mode given to WithOutputFileMode only
+// contains permission bits, and lets the owner read the output to parse it.
+func (s *Scanner) checkOutputFileMode() error {
+	if s.outputFileMode == nil {
+		return nil
+	}
+
+	mode := *s.outputFileMode
+	if mode&^os.ModePerm != 0 {
+		return fmt.Errorf("%w: output file mode %s should only contain permission bits", ErrInvalidOption, mode)
+	}
+	if mode&0400 == 0 {
+		return fmt.Errorf("%w: output file mode %s should let the owner read the file", ErrInvalidOption, mode)
+	}
+
+	return nil
+}
+
+// checkScriptArgFiles makes sure that the files referenced by script
+// arguments exist, since nmap scripts fail at runtime otherwise.
+func (s *Scanner) checkScriptArgFiles() error {
+	var errs []error
+	for _, file := range s.scriptArgFiles {
+		if _, err := os.Stat(file.path); err != nil {
+			errs = append(errs, fmt.Errorf("%w: script argument %s references a missing file: %w", ErrInvalidOption, file.key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkProfiles makes sure that all the profiles given to WithProfile were registered.
+func (s *Scanner) checkProfiles() error {
+	if len(s.unknownProfiles) > 0 {
+		return fmt.Errorf("%w: unknown scan profiles %q", ErrInvalidOption, s.unknownProfiles)
+	}
+
+	return nil
+}
+
+// parseNmapDuration parses a duration in nmap's format, which is a number
+// followed by an optional ms, s, m or h unit. Numbers without a unit are seconds.
+func parseNmapDuration(value string) (time.Duration, error) {
+	number, unit := value, "s"
+	for _, suffix := range []string{"ms", "s", "m", "h"} {
+		if strings.HasSuffix(value, suffix) {
+			number, unit = strings.TrimSuffix(value, suffix), suffix
+			break
+		}
+	}
+
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse duration %q", value)
+	}
+
+	return time.ParseDuration(strconv.FormatFloat(amount, 'f', -1, 64) + unit)
+}
+
+// hasArg returns whether the given argument was set on the scanner.
+func (s *Scanner) hasArg(arg string) bool {
+	for _, value := range s.args {
+		if value == arg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasFlag returns whether the given flag was set on the scanner, either as
+// a separate argument or with its value attached, such as --flag=value.
+func (s *Scanner) hasFlag(flag string) bool {
+	for _, value := range s.args {
+		if value == flag || strings.HasPrefix(value, flag+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// argValue returns the value following the given argument, if it was set
+// on the scanner. When the argument is repeated, the last value is returned,
+// since it is the one used by nmap.
+func (s *Scanner) argValue(arg string) (string, bool) {
+	for i := len(s.args) - 2; i >= 0; i-- {
+		if s.args[i] == arg {
+			return s.args[i+1], true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,858 @@
+package nmap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		description string
+
+		options []Option
+
+		expectedErr error
+	}{
+		{
+			description: "host discovery probes without skipping discovery",
+
+			options: []Option{
+				WithSYNDiscovery("22", "80"),
+				WithICMPEchoDiscovery(),
+			},
+		},
+		{
+			description: "skip host discovery alone",
+
+			options: []Option{
+				WithSkipHostDiscovery(),
+			},
+		},
+		{
+			description: "skip host discovery with SYN discovery",
+
+			options: []Option{
+				WithSkipHostDiscovery(),
+				WithSYNDiscovery("22"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "ICMP echo discovery with skip host discovery",
+
+			options: []Option{
+				WithICMPEchoDiscovery(),
+				WithSkipHostDiscovery(),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "targets",
+
+			options: []Option{
+				WithTargets("192.168.0.0/24", "scanme.nmap.org"),
+			},
+		},
+		{
+			description: "flag-like target",
+
+			options: []Option{
+				WithTargets("192.168.0.1", "--script=evil"),
+			},
+
+			expectedErr: ErrInvalidTarget,
+		},
+		{
+			description: "strict target validation",
+
+			options: []Option{
+				WithStrictTargetValidation(),
+				WithTargets("192.168.0.1", "10.0.0.0/8", "192.168.0-255.1-254", "scanme.nmap.org", "2001:db8::/32"),
+			},
+		},
+		{
+			description: "strict target validation with invalid targets",
+
+			options: []Option{
+				WithStrictTargetValidation(),
+				WithTargets("192.168.0.1", "192.168.0.256", "not a host", "scanme.nmap.org"),
+			},
+
+			expectedErr: ErrInvalidTarget,
+		},
+		{
+			description: "invalid targets without strict target validation",
+
+			options: []Option{
+				WithTargets("192.168.0.256", "not a host"),
+			},
+		},
+		{
+			description: "IPv6 link-local target without interface",
+
+			options: []Option{
+				WithIPv6Scanning(),
+				WithTargets("fe80::1"),
+			},
+
+			expectedErr: ErrInterfaceRequired,
+		},
+		{
+			description: "IPv6 link-local range without interface",
+
+			options: []Option{
+				WithIPv6Scanning(),
+				WithTargets("2001:db8::1", "fe80::/64"),
+			},
+
+			expectedErr: ErrInterfaceRequired,
+		},
+		{
+			description: "IPv6 link-local target with interface",
+
+			options: []Option{
+				WithIPv6Scanning(),
+				WithTargets("fe80::1"),
+				WithInterface("eth0"),
+			},
+		},
+		{
+			description: "IPv6 link-local target with zone index",
+
+			options: []Option{
+				WithIPv6Scanning(),
+				WithTargets("fe80::1%eth0"),
+			},
+		},
+		{
+			description: "IPv6 global target without interface",
+
+			options: []Option{
+				WithIPv6Scanning(),
+				WithTargets("2001:db8::1"),
+			},
+		},
+		{
+			description: "spoof random MAC",
+
+			options: []Option{
+				WithSpoofMAC("0"),
+			},
+		},
+		{
+			description: "spoof MAC vendor prefix",
+
+			options: []Option{
+				WithSpoofMAC("0020F2"),
+			},
+		},
+		{
+			description: "spoof full MAC",
+
+			options: []Option{
+				WithSpoofMAC("01:02:03:04:05:06"),
+			},
+		},
+		{
+			description: "spoof full MAC without separators",
+
+			options: []Option{
+				WithSpoofMAC("deadbeefcafe"),
+			},
+		},
+		{
+			description: "spoof MAC vendor name",
+
+			options: []Option{
+				WithSpoofMAC("Apple"),
+			},
+		},
+		{
+			description: "spoof MAC too long",
+
+			options: []Option{
+				WithSpoofMAC("01:02:03:04:05:06:07"),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "spoof MAC typo",
+
+			options: []Option{
+				WithSpoofMAC("01:02:0g:04:05:06"),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "spoof MAC empty",
+
+			options: []Option{
+				WithSpoofMAC(""),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "no retransmissions",
+
+			options: []Option{
+				WithMaxRetries(0),
+			},
+		},
+		{
+			description: "retransmissions",
+
+			options: []Option{
+				WithMaxRetries(3),
+			},
+		},
+		{
+			description: "negative retransmissions",
+
+			options: []Option{
+				WithMaxRetries(-1),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "negative retransmissions overridden",
+
+			options: []Option{
+				WithMaxRetries(-1),
+				WithMaxRetries(3),
+			},
+		},
+		{
+			description: "retransmissions overridden with negative retransmissions",
+
+			options: []Option{
+				WithMaxRetries(3),
+				WithMaxRetries(-1),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "fractional rates",
+
+			options: []Option{
+				WithMinRateFloat(0.1),
+				WithMaxRateFloat(0.5),
+			},
+		},
+		{
+			description: "zero rate",
+
+			options: []Option{
+				WithMaxRateFloat(0),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "negative rate",
+
+			options: []Option{
+				WithMinRate(-10),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "min rate greater than max rate",
+
+			options: []Option{
+				WithMinRate(100),
+				WithMaxRateFloat(0.5),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "performance profile",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinRate:        10,
+					MaxRate:        100,
+					MinParallelism: 1,
+					MaxParallelism: 10,
+					MinHostgroup:   16,
+					MaxHostgroup:   64,
+				}),
+			},
+		},
+		{
+			description: "partial performance profile",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MaxParallelism: 10,
+					MinHostgroup:   16,
+				}),
+			},
+		},
+		{
+			description: "performance profile with min parallelism greater than max parallelism",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinParallelism: 20,
+					MaxParallelism: 10,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "performance profile with min hostgroup greater than max hostgroup",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinHostgroup: 128,
+					MaxHostgroup: 64,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "performance profile with min rate greater than max rate",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinRate: 100,
+					MaxRate: 10,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "performance profile with negative parallelism",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MaxParallelism: -1,
+				}),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "performance profile with several invalid bounds",
+
+			options: []Option{
+				WithPerformanceProfile(PerformanceConfig{
+					MinRate:        100,
+					MaxRate:        10,
+					MinParallelism: 20,
+					MaxParallelism: 10,
+					MinHostgroup:   -1,
+				}),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "custom timing",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					MinRTTTimeout:     100 * time.Millisecond,
+					InitialRTTTimeout: 500 * time.Millisecond,
+					MaxRTTTimeout:     time.Second,
+					ScanDelay:         5 * time.Millisecond,
+					MaxScanDelay:      10 * time.Millisecond,
+					MinParallelism:    1,
+					MaxParallelism:    1,
+				}),
+			},
+		},
+		{
+			description: "custom timing with min RTT timeout greater than max RTT timeout",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					MinRTTTimeout: time.Second,
+					MaxRTTTimeout: 100 * time.Millisecond,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "custom timing with initial RTT timeout above max RTT timeout",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					InitialRTTTimeout: 2 * time.Second,
+					MaxRTTTimeout:     time.Second,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "custom timing with scan delay greater than max scan delay",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					ScanDelay:    time.Second,
+					MaxScanDelay: 10 * time.Millisecond,
+				}),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "custom timing with negative RTT timeout",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{
+					MinRTTTimeout: -time.Second,
+				}),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "min hostgroup greater than max hostgroup",
+
+			options: []Option{
+				WithMinHostgroup(32),
+				WithMaxHostgroup(16),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "resume from normal output",
+
+			options: []Option{
+				WithResumePreviousScan("tests/resume/scan_interrupted.nmap"),
+			},
+		},
+		{
+			description: "resume from missing file",
+
+			options: []Option{
+				WithResumePreviousScan("tests/resume/does_not_exist.nmap"),
+			},
+
+			expectedErr: os.ErrNotExist,
+		},
+		{
+			description: "resume from XML output",
+
+			options: []Option{
+				WithResumePreviousScan("tests/xml/scan_base.xml"),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "resume from empty file",
+
+			options: []Option{
+				WithResumePreviousScan(os.DevNull),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "timing template",
+
+			options: []Option{
+				WithTimingTemplate(TimingFastest),
+			},
+		},
+		{
+			description: "out of range timing template",
+
+			options: []Option{
+				WithTimingTemplate(Timing(9)),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "negative timing template",
+
+			options: []Option{
+				WithTimingTemplate(Timing(-1)),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "long scan delay with small host groups",
+
+			options: []Option{
+				WithScanDelay(5 * time.Second),
+				WithMaxHostgroup(64),
+			},
+		},
+		{
+			description: "short scan delay with large host groups",
+
+			options: []Option{
+				WithScanDelay(time.Second),
+				WithMaxHostgroup(256),
+			},
+		},
+		{
+			description: "long scan delay with large host groups",
+
+			options: []Option{
+				WithMaxHostgroup(65),
+				WithScanDelay(1001 * time.Millisecond),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "long custom scan delay with large host groups",
+
+			options: []Option{
+				WithCustomArguments("--scan-delay", "2s"),
+				WithMaxHostgroup(128),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "randomized scan delay",
+
+			options: []Option{
+				WithRandomizedScanDelay(500*time.Millisecond, 500*time.Millisecond),
+				WithMaxScanDelay(time.Second),
+				WithMaxHostgroup(256),
+			},
+		},
+		{
+			description: "randomized scan delay with scan delay",
+
+			options: []Option{
+				WithScanDelay(time.Second),
+				WithRandomizedScanDelay(time.Second, time.Second),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "negative randomized scan delay",
+
+			options: []Option{
+				WithRandomizedScanDelay(-time.Second, time.Second),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "randomized scan delay greater than max scan delay",
+
+			options: []Option{
+				WithRandomizedScanDelay(time.Second, time.Second),
+				WithMaxScanDelay(1500 * time.Millisecond),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "long randomized scan delay with large host groups",
+
+			options: []Option{
+				WithRandomizedScanDelay(500*time.Millisecond, time.Second),
+				WithMaxHostgroup(128),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "inline script arguments",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"user": "foo"}),
+			},
+		},
+		{
+			description: "script arguments file",
+
+			options: []Option{
+				WithScriptArgumentsFile("/script_args.txt"),
+			},
+		},
+		{
+			description: "inline script arguments with script arguments file",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"user": "foo"}),
+				WithScriptArgumentsFile("/script_args.txt"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "script argument referencing a file",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"userdb": "@tests/resume/scan_interrupted.nmap"}),
+			},
+		},
+		{
+			description: "script argument referencing a missing file",
+
+			options: []Option{
+				WithScriptArguments(map[string]string{"userdb": "@tests/does_not_exist.txt"}),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "custom inline script arguments with script arguments file",
+
+			options: []Option{
+				WithScriptArgumentsFile("/script_args.txt"),
+				WithCustomArguments("--script-args", "user=foo"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "ping scan with ports",
+
+			options: []Option{
+				WithPingScan(),
+				WithPorts("22", "80"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "ping scan without ports",
+
+			options: []Option{
+				WithPingScan(),
+			},
+		},
+		{
+			description: "IP protocol scan with protocol numbers",
+
+			options: []Option{
+				WithIPProtocolScan(),
+				WithPorts("1", "6", "17", "50-51"),
+			},
+		},
+		{
+			description: "IP protocol scan with ports",
+
+			options: []Option{
+				WithIPProtocolScan(),
+				WithPorts("443"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "IP protocol scan with port range",
+
+			options: []Option{
+				WithIPProtocolScan(),
+				WithPorts("1-1024"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "IP protocol scan with TCP ports",
+
+			options: []Option{
+				WithIPProtocolScan(),
+				WithTCPPorts("6"),
+			},
+
+			expectedErr: ErrConflictingOptions,
+		},
+		{
+			description: "output file mode",
+
+			options: []Option{
+				WithOutputFileMode(0640),
+			},
+		},
+		{
+			description: "output file mode with file type bits",
+
+			options: []Option{
+				WithOutputFileMode(os.ModeDir | 0755),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "output file mode unreadable by owner",
+
+			options: []Option{
+				WithOutputFileMode(0244),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "host timeout",
+
+			options: []Option{
+				WithHostTimeout(30 * time.Second),
+			},
+		},
+		{
+			description: "zero host timeout",
+
+			options: []Option{
+				WithHostTimeout(0),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "sub-millisecond host timeout",
+
+			options: []Option{
+				WithHostTimeout(500 * time.Microsecond),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "zero per-host timeout",
+
+			options: []Option{
+				WithPerHostTimeout(0),
+			},
+
+			expectedErr: ErrInvalidOption,
+		},
+		{
+			description: "custom timing without host timeout",
+
+			options: []Option{
+				WithCustomTiming(BuildTiming{MaxRetries: 2}),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			s, err := NewScanner(context.TODO(), test.options...)
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+
+			if test.expectedErr != nil && s != nil {
+				t.Error("expected NewScanner to return a nil scanner on validation failure")
+			}
+		})
+	}
+}
+
+func TestRunValidatesAddedOptions(t *testing.T) {
+	s, err := NewScanner(
+		context.TODO(),
+		WithBinaryPath("tests/scripts/fake_nmap.sh"),
+		WithCustomArguments("tests/xml/scan_base.xml"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	var ran bool
+	s.AddOptions(WithMaxRetries(-1), WithBeforeRun(func([]string) { ran = true }))
+
+	_, _, err = s.Run()
+	if !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected error %v, got %v", ErrInvalidOption, err)
+	}
+
+	if ran {
+		t.Error("expected the scan not to run with invalid options")
+	}
+}
+
+func TestParseNmapDuration(t *testing.T) {
+	tests := []struct {
+		value string
+
+		expectedDuration time.Duration
+		expectedErr      bool
+	}{
+		{value: "500ms", expectedDuration: 500 * time.Millisecond},
+		{value: "2s", expectedDuration: 2 * time.Second},
+		{value: "1.5m", expectedDuration: 90 * time.Second},
+		{value: "1h", expectedDuration: time.Hour},
+		{value: "3", expectedDuration: 3 * time.Second},
+		{value: "fast", expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			duration, err := parseNmapDuration(test.value)
+			if (err != nil) != test.expectedErr {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			if duration != test.expectedDuration {
+				t.Errorf("expected duration %s, got %s", test.expectedDuration, duration)
+			}
+		})
+	}
+}
+
+func TestIsValidTarget(t *testing.T) {
+	tests := []struct {
+		target string
+
+		expectedValid bool
+	}{
+		{target: "192.168.0.1", expectedValid: true},
+		{target: "192.168.0.0/24", expectedValid: true},
+		{target: "0.0.0.0/0", expectedValid: true},
+		{target: "2001:db8::1", expectedValid: true},
+		{target: "2001:db8::/32", expectedValid: true},
+		{target: "fe80::1%eth0", expectedValid: true},
+		{target: "192.168.0-255.1-254", expectedValid: true},
+		{target: "192.168.3-5,7.1", expectedValid: true},
+		{target: "10.0.*.1", expectedValid: true},
+		{target: "10.0.-100.1", expectedValid: true},
+		{target: "scanme.nmap.org", expectedValid: true},
+		{target: "scanme.nmap.org/24", expectedValid: true},
+		{target: "localhost", expectedValid: true},
+		{target: "my-host.example.com.", expectedValid: true},
+		{target: "192.168.0.256", expectedValid: false},
+		{target: "192.168.0.1/33", expectedValid: false},
+		{target: "192.168.0.1/abc", expectedValid: false},
+		{target: "2001:db8::/129", expectedValid: false},
+		{target: "192.168.0-300.1", expectedValid: false},
+		{target: "192.168.1", expectedValid: false},
+		{target: "not a host", expectedValid: false},
+		{target: "-host.example.com", expectedValid: false},
+		{target: "host_name.example.com", expectedValid: false},
+		{target: "", expectedValid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			if valid := isValidTarget(test.target); valid != test.expectedValid {
+				t.Errorf("expected target %q validity to be %t", test.target, test.expectedValid)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package nmap
+
+import (
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Vulnerability is a vulnerability reported by an NSE script, such as
+// vulners or the scripts of the vuln category.
+type Vulnerability struct {
+	// Host is the address of the vulnerable host, which is empty for
+	// vulnerabilities reported by pre-scan and post-scan scripts.
+	Host string `json:"host"`
+	// Port is the vulnerable port, which is zero for host scripts.
+	Port     uint16 `json:"port"`
+	Protocol string `json:"protocol"`
+	Script   string `json:"script"`
+
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	// State is the state reported by the vulns library, such as VULNERABLE
+	// or NOT VULNERABLE. It is empty for vulners results.
+	State     string  `json:"state"`
+	CVSS      float64 `json:"cvss"`
+	IsExploit bool    `json:"is_exploit"`
+	// CPE is the CPE which vulners matched the vulnerability against.
+	CPE string `json:"cpe"`
+}
+
+// Vulnerabilities returns the vulnerabilities reported by the vulners script
+// and the scripts using nmap's vulns library, from the pre-scan, host, port
+// and post-scan scripts of the run. Other scripts are ignored.
+func (r Run) Vulnerabilities() []Vulnerability {
+	var vulns []Vulnerability
+	for _, ref := range r.AllScripts() {
+		vulns = append(vulns, scriptVulnerabilities(ref.Script, Vulnerability{
+			Host:     ref.Host,
+			Port:     ref.Port,
+			Protocol: ref.Protocol,
+		})...)
+	}
+
+	return vulns
+}
+
+// scriptVulnerabilities parses the vulnerabilities reported by a script,
+// using the given location for each of them.
+func scriptVulnerabilities(script Script, location Vulnerability) []Vulnerability {
+	location.Script = script.ID
+
+	var vulns []Vulnerability
+	for _, table := range script.Tables {
+		if script.ID == "vulners" {
+			vulns = append(vulns, vulnersVulnerabilities(table, location)...)
+			continue
+		}
+
+		// Tables of the vulns library always contain a state.
+		state, ok := tableElement(table, "state")
+		if !ok {
+			continue
+		}
+
+		vuln := location
+		vuln.ID = table.Key
+		vuln.State = state
+		vuln.Title, _ = tableElement(table, "title")
+		vuln.CVSS = parseCVSS(tableElement(table, "cvss"))
+
+		for _, subTable := range table.Tables {
+			switch subTable.Key {
+			case "ids":
+				// IDs are formatted as TYPE:ID, such as CVE:CVE-2017-0143.
+				if len(subTable.Elements) > 0 {
+					idType, id, found := strings.Cut(elementValue(subTable.Elements[0]), ":")
+					if found {
+						vuln.Type = strings.ToLower(idType)
+						vuln.ID = id
+					}
+				}
+			case "scores":
+				if len(subTable.Elements) > 0 && vuln.CVSS == 0 {
+					vuln.CVSS = parseCVSS(elementValue(subTable.Elements[0]), true)
+				}
+			}
+		}
+
+		vulns = append(vulns, vuln)
+	}
+
+	return vulns
+}
+
+// vulnersVulnerabilities parses the vulnerabilities that the vulners script
+// found for a single CPE.
+func vulnersVulnerabilities(table Table, location Vulnerability) []Vulnerability {
+	var vulns []Vulnerability
+	for _, entry := range table.Tables {
+		vuln := location
+		vuln.CPE = table.Key
+		vuln.ID, _ = tableElement(entry, "id")
+		vuln.Type, _ = tableElement(entry, "type")
+		vuln.CVSS = parseCVSS(tableElement(entry, "cvss"))
+
+		isExploit, _ := tableElement(entry, "is_exploit")
+		vuln.IsExploit = isExploit == "true"
+
+		vulns = append(vulns, vuln)
+	}
+
+	return vulns
+}
+
+// tableElement returns the value of the element of the table with the given key.
+func tableElement(table Table, key string) (string, bool) {
+	for _, element := range table.Elements {
+		if element.Key == key {
+			return elementValue(element), true
+		}
+	}
+
+	return "", false
+}
+
+// elementValue returns the unescaped value of an element.
+func elementValue(element Element) string {
+	return html.UnescapeString(strings.TrimSpace(element.Value))
+}
+
+// parseCVSS parses a CVSS score, returning zero if it is missing or invalid.
+func parseCVSS(value string, found bool) float64 {
+	if !found {
+		return 0
+	}
+
+	score, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+
+	return score
+}
@@ -0,0 +1,87 @@
+package nmap
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVulnerabilities(t *testing.T) {
+	rawXML, err := os.ReadFile("tests/xml/scan_vulns.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedVulns := []Vulnerability{
+		{
+			Host:   "192.168.1.10",
+			Script: "smb-vuln-ms17-010",
+			ID:     "CVE-2017-0143",
+			Type:   "cve",
+			Title:  "Remote Code Execution vulnerability in Microsoft SMBv1 servers (ms17-010)",
+			State:  "VULNERABLE",
+		},
+		{
+			Host:   "192.168.1.10",
+			Script: "smb-double-pulsar-backdoor",
+			ID:     "NMAP-12",
+			Title:  "Double Pulsar SMB Backdoor",
+			State:  "NOT VULNERABLE",
+		},
+		{
+			Host:      "192.168.1.10",
+			Port:      22,
+			Protocol:  "tcp",
+			Script:    "vulners",
+			ID:        "PACKETSTORM:173661",
+			Type:      "packetstorm",
+			CVSS:      9.8,
+			IsExploit: true,
+			CPE:       "cpe:/a:openbsd:openssh:7.4",
+		},
+		{
+			Host:     "192.168.1.10",
+			Port:     22,
+			Protocol: "tcp",
+			Script:   "vulners",
+			ID:       "CVE-2023-38408",
+			Type:     "cve",
+			CVSS:     9.8,
+			CPE:      "cpe:/a:openbsd:openssh:7.4",
+		},
+		{
+			Host:     "192.168.1.10",
+			Port:     22,
+			Protocol: "tcp",
+			Script:   "vulners",
+			ID:       "CVE-2016-10012",
+			Type:     "cve",
+			CVSS:     7.8,
+			CPE:      "cpe:/a:openbsd:openssh:7.4",
+		},
+	}
+
+	assert.Equal(t, expectedVulns, result.Vulnerabilities())
+}
+
+func TestVulnerabilitiesWithoutScripts(t *testing.T) {
+	rawXML, err := os.ReadFile("tests/xml/scan_tcp_udp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, result.Vulnerabilities())
+}
@@ -2,10 +2,18 @@ package nmap
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	family "github.com/Ullaakut/nmap/v3/pkg/osfamilies"
@@ -23,7 +31,7 @@ type Run struct {
 	XMLOutputVersion string         `xml:"xmloutputversion,attr" json:"xml_output_version"`
 	Debugging        Debugging      `xml:"debugging" json:"debugging"`
 	Stats            Stats          `xml:"runstats" json:"run_stats"`
-	ScanInfo         ScanInfo       `xml:"scaninfo" json:"scan_info"`
+	ScanInfos        []ScanInfo     `xml:"scaninfo" json:"scan_infos"`
 	Start            Timestamp      `xml:"start,attr" json:"start"`
 	Verbose          Verbose        `xml:"verbose" json:"verbose"`
 	Hosts            []Host         `xml:"host" json:"hosts"`
@@ -34,20 +42,39 @@ type Run struct {
 	TaskProgress     []TaskProgress `xml:"taskprogress" json:"task_progress"`
 	TaskEnd          []Task         `xml:"taskend" json:"task_end"`
 
-	NmapErrors []string
-	rawXML     []byte
+	// ScanInfo is the first entry of ScanInfos. It is kept for backward compatibility,
+	// but only describes one protocol when combining scan types such as TCP and UDP.
+	ScanInfo ScanInfo `xml:"-" json:"scan_info"`
+
+	NmapErrors  []string
+	warnings    []string
+	scriptTrace []string
+	sourceIface string
+	rawXML      []byte
+	truncated   bool
+	scanID      string
 }
 
 // ToFile writes a Run as XML into the specified file path.
+// Runs that were not parsed from nmap's output, such as the ones
+// returned by MergeRuns, are marshalled from their current fields.
 func (r Run) ToFile(filePath string) error {
-	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		return err
+	rawXML := r.rawXML
+	if len(rawXML) == 0 {
+		var err error
+		rawXML, err = xml.Marshal(r)
+		if err != nil {
+			return err
+		}
 	}
-	_, err = file.Write(r.rawXML)
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return err
 	}
+	defer file.Close()
+
+	_, err = file.Write(rawXML)
 	return err
 }
 
@@ -56,6 +83,297 @@ func (r Run) ToReader() io.Reader {
 	return bytes.NewReader(r.rawXML)
 }
 
+// WriteCSV writes a flat CSV export of the Run into the given writer, with one
+// row per scanned port. The columns are host, port, protocol, state, service,
+// product and version. Hosts without any port are written as a single row with
+// empty port fields.
+func (r Run) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"host", "port", "protocol", "state", "service", "product", "version"})
+	if err != nil {
+		return err
+	}
+
+	for _, host := range r.Hosts {
+		var address string
+		if len(host.Addresses) > 0 {
+			address = host.Addresses[0].Addr
+		}
+
+		if len(host.Ports) == 0 {
+			err = writer.Write([]string{address, "", "", "", "", "", ""})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, port := range host.Ports {
+			err = writer.Write([]string{
+				address,
+				fmt.Sprint(port.ID),
+				port.Protocol,
+				port.State.State,
+				port.Service.Name,
+				port.Service.Product,
+				port.Service.Version,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// DownHosts returns the hosts that nmap reported as down. Nmap only includes
+// down hosts in its output when verbosity is enabled, using WithVerbosity.
+func (r Run) DownHosts() []Host {
+	var hosts []Host
+	for _, host := range r.Hosts {
+		if host.Status.State == "down" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// UpHosts returns the hosts that nmap reported as up.
+func (r Run) UpHosts() []Host {
+	var hosts []Host
+	for _, host := range r.Hosts {
+		if host.Status.State == "up" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// KeepUpHosts removes the hosts that nmap did not report as up from the run,
+// for example to only serialize live hosts. Unlike UpHosts, it modifies the run.
+func (r *Run) KeepUpHosts() {
+	r.Hosts = r.UpHosts()
+}
+
+// HostCount returns the number of hosts in the run, including hosts
+// that are down.
+func (r Run) HostCount() int {
+	return len(r.Hosts)
+}
+
+// HasHosts returns whether the run contains any host.
+func (r Run) HasHosts() bool {
+	return len(r.Hosts) > 0
+}
+
+// UnidentifiedServices returns the ports of all hosts on which nmap could not
+// identify the running service, along with the fingerprint of the service.
+func (r Run) UnidentifiedServices() []Port {
+	var ports []Port
+	for _, host := range r.Hosts {
+		for _, port := range host.Ports {
+			if port.IsUnidentifiedService() {
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	return ports
+}
+
+// ListedTargets returns the targets of the run, in order, such as the ones listed
+// by a list scan using WithListScan. Hosts which were given to nmap as hostnames
+// are listed using that hostname, and the other ones using their first address
+// which isn't a MAC address.
+func (r Run) ListedTargets() []string {
+	targets := make([]string, 0, len(r.Hosts))
+	for _, host := range r.Hosts {
+		if target := host.listedTarget(); target != "" {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+// listedTarget returns the hostname the host was given to nmap as, or its
+// first address which isn't a MAC address, or an empty string if it has none.
+func (h Host) listedTarget() string {
+	for _, hostname := range h.Hostnames {
+		if hostname.Type == "user" {
+			return hostname.Name
+		}
+	}
+
+	for _, address := range h.Addresses {
+		if address.AddrType != "mac" {
+			return address.Addr
+		}
+	}
+
+	return ""
+}
+
+// ExcludeCIDR removes the hosts whose primary IP address is within any of
+// the given CIDRs from the run, such as the scanning infrastructure's own
+// addresses. Hosts without an IP address are kept. The run is left unchanged
+// if any of the CIDRs is invalid.
+func (r *Run) ExcludeCIDR(cidrs ...string) error {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		networks = append(networks, network)
+	}
+
+	hosts := make([]Host, 0, len(r.Hosts))
+	for _, host := range r.Hosts {
+		if !containsIP(networks, host.primaryIP()) {
+			hosts = append(hosts, host)
+		}
+	}
+	r.Hosts = hosts
+
+	return nil
+}
+
+// containsIP returns whether the given IP is within any of the networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SortHostsByAddress sorts the hosts of the run by their primary IP address in
+// numeric order, such as to make reports deterministic. IPv4 addresses come
+// before IPv6 addresses, and hosts without an IP address come last, in their
+// original order.
+func (r *Run) SortHostsByAddress() {
+	sort.SliceStable(r.Hosts, func(i, j int) bool {
+		return compareIPs(r.Hosts[i].primaryIP(), r.Hosts[j].primaryIP()) < 0
+	})
+}
+
+// SortHostsByOpenPorts sorts the hosts of the run by their number of open ports,
+// the hosts with the most open ports first. Hosts with as many open ports are
+// sorted by address, as done by SortHostsByAddress.
+func (r *Run) SortHostsByOpenPorts() {
+	sort.SliceStable(r.Hosts, func(i, j int) bool {
+		openI, openJ := len(r.Hosts[i].OpenPorts()), len(r.Hosts[j].OpenPorts())
+		if openI != openJ {
+			return openI > openJ
+		}
+
+		return compareIPs(r.Hosts[i].primaryIP(), r.Hosts[j].primaryIP()) < 0
+	})
+}
+
+// compareIPs compares two IP addresses numerically, IPv4 addresses coming before
+// IPv6 addresses, and nil addresses coming last. It returns a negative number if
+// a comes first, a positive number if b comes first, and 0 if they are equal.
+func compareIPs(a, b net.IP) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	a4, b4 := a.To4(), b.To4()
+	switch {
+	case a4 != nil && b4 != nil:
+		return bytes.Compare(a4, b4)
+	case a4 != nil:
+		return -1
+	case b4 != nil:
+		return 1
+	}
+
+	return bytes.Compare(a.To16(), b.To16())
+}
+
+// StartedAt returns the time at which the scan started.
+func (r Run) StartedAt() time.Time {
+	return time.Time(r.Start)
+}
+
+// FinishedAt returns the time at which the scan finished, or the zero
+// time if the run statistics are missing, such as for an interrupted scan.
+func (r Run) FinishedAt() time.Time {
+	return time.Time(r.Stats.Finished.Time)
+}
+
+// Warnings returns the warnings that nmap printed during the scan.
+func (r Run) Warnings() []string {
+	return r.warnings
+}
+
+// HasWarnings returns whether nmap printed any warning during the scan.
+func (r Run) HasWarnings() bool {
+	return len(r.warnings) > 0
+}
+
+// WarningCount returns the number of warnings that nmap printed during the scan.
+func (r Run) WarningCount() int {
+	return len(r.warnings)
+}
+
+// ScriptTrace returns the NSE traffic that nmap printed during the scan when
+// WithScriptTrace is used. Those lines are not part of the warnings.
+func (r Run) ScriptTrace() []string {
+	return r.scriptTrace
+}
+
+// SourceInterface returns the network interface nmap used to send its probes,
+// on a best-effort basis. Nmap only reports it in its debugging output, so it is
+// only available when using WithDebugging, and is empty otherwise.
+func (r Run) SourceInterface() string {
+	return r.sourceIface
+}
+
+// ScanID returns the identifier given to the scanner using WithScanID, or an
+// empty string if none was given.
+func (r Run) ScanID() string {
+	return r.scanID
+}
+
+// Truncated returns whether hosts were left out of the run because the
+// limit set using WithMaxHosts was reached.
+func (r Run) Truncated() bool {
+	return r.truncated
+}
+
+// Err returns an error joining all of the fatal warnings that nmap printed
+// during the scan, or nil if there were none. Each joined error wraps one of
+// the package's sentinel errors, so it can be checked using errors.Is.
+func (r Run) Err() error {
+	var errs []error
+	for _, warning := range r.warnings {
+		if err := warningError(warning); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (r *Run) FromFile(filename string) error {
 	readFile, err := os.ReadFile(filename)
 	if err != nil {
@@ -131,11 +449,119 @@ type Host struct {
 	Smurfs        []Smurf       `xml:"smurf" json:"smurfs"`
 }
 
+// AddressesByType returns the addresses of the host of the given type,
+// such as "ipv4", "ipv6" or "mac", in the order in which nmap reported them.
+func (h Host) AddressesByType(addrType string) []Address {
+	var addresses []Address
+	for _, address := range h.Addresses {
+		if address.AddrType == addrType {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+// primaryIP returns the first IPv4 or IPv6 address of the host, or nil if it has none.
+func (h Host) primaryIP() net.IP {
+	for _, address := range h.Addresses {
+		if address.AddrType != "ipv4" && address.AddrType != "ipv6" {
+			continue
+		}
+
+		if ip := net.ParseIP(address.Addr); ip != nil {
+			return ip
+		}
+	}
+
+	return nil
+}
+
+// OpenPorts returns the ports of the host which nmap found to be open.
+// Ports reported as open|filtered, which commonly happens for UDP ports that
+// don't respond to probes, are not included since nmap could not determine
+// whether they are open.
+func (h Host) OpenPorts() []Port {
+	var ports []Port
+	for _, port := range h.Ports {
+		if port.Status() == Open {
+			ports = append(ports, port)
+		}
+	}
+
+	return ports
+}
+
+// initialTTLs are the most common initial TTLs used by operating systems,
+// such as 64 for Linux and macOS, 128 for Windows and 255 for network devices.
+var initialTTLs = []int{64, 128, 255}
+
+// EstimatedHopDistance returns the distance to the host in hops, using the
+// same scale as nmap where 1 means that the host is on the local network.
+// It returns the distance reported by nmap when it is available. Otherwise,
+// it is inferred from the TTL of the responses of the host, assuming that it
+// used the closest common initial TTL above it (64, 128 or 255). This is only
+// a heuristic, which is wrong for hosts using uncommon initial TTLs or more
+// than 64 hops away. It returns -1 if no response TTL was reported.
+func (h Host) EstimatedHopDistance() int {
+	if h.Distance.Value > 0 {
+		return h.Distance.Value
+	}
+
+	ttl := int(h.Status.ReasonTTL)
+	for _, port := range h.Ports {
+		if ttl > 0 {
+			break
+		}
+		ttl = int(port.State.ReasonTTL)
+	}
+	if ttl <= 0 {
+		return -1
+	}
+
+	for _, initialTTL := range initialTTLs {
+		if ttl <= initialTTL {
+			return initialTTL - ttl + 1
+		}
+	}
+
+	return -1
+}
+
+// OSCPEs returns the CPEs of the best OS match of the host, which is the one
+// with the highest accuracy. CPEs of less accurate matches are left out.
+// It returns nil if OS detection did not find any match.
+func (h Host) OSCPEs() []CPE {
+	if len(h.OS.Matches) == 0 {
+		return nil
+	}
+
+	best := h.OS.Matches[0]
+	for _, match := range h.OS.Matches[1:] {
+		if match.Accuracy > best.Accuracy {
+			best = match
+		}
+	}
+
+	var cpes []CPE
+	seen := make(map[CPE]bool)
+	for _, class := range best.Classes {
+		for _, cpe := range class.CPEs {
+			if !seen[cpe] {
+				seen[cpe] = true
+				cpes = append(cpes, cpe)
+			}
+		}
+	}
+
+	return cpes
+}
+
 // Status represents a host's status.
 type Status struct {
-	State     string  `xml:"state,attr" json:"state"`
-	Reason    string  `xml:"reason,attr" json:"reason"`
-	ReasonTTL float32 `xml:"reason_ttl,attr" json:"reason_ttl"`
+	State     string    `xml:"state,attr" json:"state"`
+	Reason    string    `xml:"reason,attr" json:"reason"`
+	ReasonTTL ReasonTTL `xml:"reason_ttl,attr" json:"reason_ttl"`
 }
 
 func (s Status) String() string {
@@ -183,6 +609,11 @@ type Reason struct {
 }
 
 // Port contains all the information about a scanned port.
+//
+// Protocol is the transport protocol the port was scanned over, reported by nmap
+// in lowercase, such as "tcp". Use TransportProtocol to compare it against the
+// TransportProtocol constants regardless of casing. It is unrelated to the Proto field
+// of the port's Service, returned by ServiceProtocol, which is only set for RPC services.
 type Port struct {
 	ID       uint16   `xml:"portid,attr" json:"id"`
 	Protocol string   `xml:"protocol,attr" json:"protocol"`
@@ -197,10 +628,12 @@ type PortStatus string
 
 // Enumerates the different possible state values.
 const (
-	Open       PortStatus = "open"
-	Closed     PortStatus = "closed"
-	Filtered   PortStatus = "filtered"
-	Unfiltered PortStatus = "unfiltered"
+	Open           PortStatus = "open"
+	Closed         PortStatus = "closed"
+	Filtered       PortStatus = "filtered"
+	Unfiltered     PortStatus = "unfiltered"
+	OpenFiltered   PortStatus = "open|filtered"
+	ClosedFiltered PortStatus = "closed|filtered"
 )
 
 // Status returns the status of a port.
@@ -208,13 +641,62 @@ func (p Port) Status() PortStatus {
 	return PortStatus(p.State.State)
 }
 
+// IsTLS returns whether nmap detected that the service running on
+// the port is tunneled through SSL/TLS, such as HTTP on port 443.
+func (p Port) IsTLS() bool {
+	return p.Service.IsEncrypted()
+}
+
+// ServiceProbed returns whether the service running on the port was detected
+// by probing it, rather than guessed from the port number using nmap's services table.
+func (p Port) ServiceProbed() bool {
+	return p.Service.Method == "probed"
+}
+
+// IsUnidentifiedService returns whether nmap could not identify the service running
+// on the port, in which case it reports the fingerprint of the service, which can be
+// submitted to nmap to improve its version detection.
+func (p Port) IsUnidentifiedService() bool {
+	return p.Service.ServiceFP != "" && (p.Service.Name == "" || p.Service.Name == "unknown")
+}
+
+// TransportProtocol represents the transport protocol of a port.
+type TransportProtocol string
+
+// Enumerates the different possible transport protocols.
+const (
+	TCP  TransportProtocol = "tcp"
+	UDP  TransportProtocol = "udp"
+	SCTP TransportProtocol = "sctp"
+	IP   TransportProtocol = "ip"
+)
+
+// TransportProtocol returns the transport protocol the port was scanned over, such as
+// TCP or UDP, normalized to lowercase so that it can be compared to the TransportProtocol
+// constants. Unlike ServiceProtocol, it is always set.
+func (p Port) TransportProtocol() TransportProtocol {
+	return TransportProtocol(strings.ToLower(p.Protocol))
+}
+
+// Proto is an alias of TransportProtocol.
+func (p Port) Proto() TransportProtocol {
+	return p.TransportProtocol()
+}
+
+// ServiceProtocol returns the protocol of the service running on the port, as
+// detected by nmap's version detection. Nmap only reports it for RPC services,
+// for which it is "rpc", and it is empty otherwise.
+func (p Port) ServiceProtocol() string {
+	return p.Service.Proto
+}
+
 // State contains information about a given port's status.
 // State will be open, closed, etc.
 type State struct {
-	State     string  `xml:"state,attr" json:"state"`
-	Reason    string  `xml:"reason,attr" json:"reason"`
-	ReasonIP  string  `xml:"reason_ip,attr" json:"reason_ip"`
-	ReasonTTL float32 `xml:"reason_ttl,attr" json:"reason_ttl"`
+	State     string    `xml:"state,attr" json:"state"`
+	Reason    string    `xml:"reason,attr" json:"reason"`
+	ReasonIP  string    `xml:"reason_ip,attr" json:"reason_ip"`
+	ReasonTTL ReasonTTL `xml:"reason_ttl,attr" json:"reason_ttl"`
 }
 
 func (s State) String() string {
@@ -231,6 +713,9 @@ func (o Owner) String() string {
 }
 
 // Service contains detailed information about a service on an open port.
+//
+// Proto is only set by nmap for RPC services, to "rpc". The transport protocol
+// of the service is the Protocol of its port.
 type Service struct {
 	DeviceType  string `xml:"devicetype,attr" json:"device_type"`
 	ExtraInfo   string `xml:"extrainfo,attr" json:"extra_info"`
@@ -254,10 +739,129 @@ func (s Service) String() string {
 	return s.Name
 }
 
+// FullDescription returns a human-readable description of the service, the way
+// nmap shows it in its version column, such as "Apache httpd 1.3.39 ((Unix) PHP/4.4.7)".
+// The operating system of the service is appended when known, such as "OpenSSH 7.4; OS: Linux".
+func (s Service) FullDescription() string {
+	var parts []string
+	for _, part := range []string{s.Product, s.Version} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if s.ExtraInfo != "" {
+		parts = append(parts, "("+s.ExtraInfo+")")
+	}
+
+	description := strings.Join(parts, " ")
+	if s.OSType == "" {
+		return description
+	}
+
+	if description == "" {
+		return "OS: " + s.OSType
+	}
+
+	return description + "; OS: " + s.OSType
+}
+
+// IsEncrypted returns whether the service is tunneled through SSL/TLS.
+func (s Service) IsEncrypted() bool {
+	return s.Tunnel == "ssl"
+}
+
 // CPE (Common Platform Enumeration) is a standardized way to name software
 // applications, operating systems and hardware platforms.
 type CPE string
 
+// CPEComponents are the components of a CPE. Components which are missing from
+// the CPE, or match any value, are empty.
+type CPEComponents struct {
+	// Part is "a" for applications, "o" for operating systems and "h" for hardware.
+	Part    string `json:"part"`
+	Vendor  string `json:"vendor"`
+	Product string `json:"product"`
+	Version string `json:"version"`
+	Update  string `json:"update"`
+}
+
+// Parse splits the CPE into its components. Both the CPE 2.2 URIs that nmap reports,
+// such as cpe:/a:openbsd:openssh:8.9, and the CPE 2.3 formatted strings, such as
+// cpe:2.3:a:openbsd:openssh:8.9:*:*:*:*:*:*:*, are supported. It returns ErrInvalidCPE
+// if the CPE is in neither format.
+func (c CPE) Parse() (CPEComponents, error) {
+	var components []string
+	if uri, ok := strings.CutPrefix(string(c), "cpe:/"); ok {
+		for _, component := range strings.Split(uri, ":") {
+			unescaped, err := url.PathUnescape(component)
+			if err != nil {
+				return CPEComponents{}, fmt.Errorf("%w: %s: %w", ErrInvalidCPE, c, err)
+			}
+			components = append(components, unescaped)
+		}
+	} else if formatted, ok := strings.CutPrefix(string(c), "cpe:2.3:"); ok {
+		components = splitCPE23(formatted)
+	} else {
+		return CPEComponents{}, fmt.Errorf("%w: %s", ErrInvalidCPE, c)
+	}
+
+	switch components[0] {
+	case "a", "o", "h":
+	default:
+		return CPEComponents{}, fmt.Errorf("%w: %s has an unknown part %q", ErrInvalidCPE, c, components[0])
+	}
+
+	// Pad the components so that missing ones are empty.
+	for len(components) < 5 {
+		components = append(components, "")
+	}
+
+	return CPEComponents{
+		Part:    components[0],
+		Vendor:  components[1],
+		Product: components[2],
+		Version: components[3],
+		Update:  components[4],
+	}, nil
+}
+
+// splitCPE23 splits a CPE 2.3 formatted string into its unescaped components,
+// in which special characters such as colons are escaped using backslashes.
+// Components which match any value, written as an unescaped *, are empty.
+func splitCPE23(formatted string) []string {
+	var components []string
+	var component strings.Builder
+	var escaped, hasEscapes bool
+
+	endComponent := func() {
+		value := component.String()
+		if value == "*" && !hasEscapes {
+			value = ""
+		}
+		components = append(components, value)
+		component.Reset()
+		hasEscapes = false
+	}
+
+	for _, char := range formatted {
+		switch {
+		case escaped:
+			component.WriteRune(char)
+			escaped = false
+		case char == '\\':
+			escaped = true
+			hasEscapes = true
+		case char == ':':
+			endComponent()
+		default:
+			component.WriteRune(char)
+		}
+	}
+	endComponent()
+
+	return components
+}
+
 // Script represents an Nmap Scripting Engine script.
 // The inner elements can be an arbitrary collection of Tables and Elements. Both of them can also be empty.
 type Script struct {
@@ -267,6 +871,69 @@ type Script struct {
 	Tables   []Table   `xml:"table,omitempty" json:"tables,omitempty"`
 }
 
+// ScriptContext is the context in which an NSE script ran.
+type ScriptContext string
+
+// Enumerates the contexts in which NSE scripts run.
+const (
+	ScriptContextPreScan  ScriptContext = "prescan"
+	ScriptContextHost     ScriptContext = "host"
+	ScriptContextPort     ScriptContext = "port"
+	ScriptContextPostScan ScriptContext = "postscan"
+)
+
+// ScriptRef is the result of an NSE script along with the context in which it ran.
+type ScriptRef struct {
+	Script  Script        `json:"script"`
+	Context ScriptContext `json:"context"`
+	// Host is the address of the host the script ran against, which is empty
+	// for pre-scan and post-scan scripts.
+	Host string `json:"host"`
+	// Port is the port the script ran against, which is zero unless the
+	// script ran in the port context.
+	Port     uint16 `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// AllScripts returns the results of all of the NSE scripts of the run, from
+// the pre-scan scripts to the host and port scripts of each host, followed by
+// the post-scan scripts.
+func (r Run) AllScripts() []ScriptRef {
+	var scripts []ScriptRef
+	for _, script := range r.PreScripts {
+		scripts = append(scripts, ScriptRef{Script: script, Context: ScriptContextPreScan})
+	}
+
+	for _, host := range r.Hosts {
+		var address string
+		if len(host.Addresses) > 0 {
+			address = host.Addresses[0].Addr
+		}
+
+		for _, script := range host.HostScripts {
+			scripts = append(scripts, ScriptRef{Script: script, Context: ScriptContextHost, Host: address})
+		}
+
+		for _, port := range host.Ports {
+			for _, script := range port.Scripts {
+				scripts = append(scripts, ScriptRef{
+					Script:   script,
+					Context:  ScriptContextPort,
+					Host:     address,
+					Port:     port.ID,
+					Protocol: port.Protocol,
+				})
+			}
+		}
+	}
+
+	for _, script := range r.PostScripts {
+		scripts = append(scripts, ScriptRef{Script: script, Context: ScriptContextPostScan})
+	}
+
+	return scripts
+}
+
 // Table is an arbitrary collection of (sub-)Tables and Elements. All its fields can be empty.
 type Table struct {
 	Key      string    `xml:"key,attr,omitempty" json:"key,omitempty"`
@@ -359,6 +1026,27 @@ type Trace struct {
 	Hops  []Hop  `xml:"hop" json:"hops"`
 }
 
+// NamedHops returns the hops of the trace which were resolved to a hostname.
+func (t Trace) NamedHops() []Hop {
+	var hops []Hop
+	for _, hop := range t.Hops {
+		if hop.Host != "" {
+			hops = append(hops, hop)
+		}
+	}
+
+	return hops
+}
+
+// LastHop returns the last hop of the trace, or nil if the trace has no hops.
+func (t Trace) LastHop() *Hop {
+	if len(t.Hops) == 0 {
+		return nil
+	}
+
+	return &t.Hops[len(t.Hops)-1]
+}
+
 // Hop is an IP hop to a host.
 type Hop struct {
 	TTL    float32 `xml:"ttl,attr" json:"ttl"`
@@ -397,6 +1085,49 @@ type HostStats struct {
 	Total int `xml:"total,attr" json:"total"`
 }
 
+// SummaryStats contains the statistics that nmap gives in the summary sentence of a finished scan.
+type SummaryStats struct {
+	Addresses int
+	HostsUp   int
+	Elapsed   float32
+}
+
+// summaryRegex matches the statistics of nmap's summary sentence, such as:
+// Nmap done at Sun Jan 27 21:52:49 2008; 8 IP addresses (8 hosts up) scanned in 2567.75 seconds
+var summaryRegex = regexp.MustCompile(`(\d+) IP address(?:es)? \((\d+) hosts? up\) scanned in (\d+(?:\.\d+)?) seconds`)
+
+// ParseSummary extracts the number of scanned addresses, the number of hosts up and
+// the elapsed time in seconds from the summary sentence of the run, which is useful
+// when the other statistics of the run are missing. It returns ErrInvalidSummary if
+// the run has no summary, or if it can't be parsed.
+func (r Run) ParseSummary() (SummaryStats, error) {
+	matches := summaryRegex.FindStringSubmatch(r.Stats.Finished.Summary)
+	if matches == nil {
+		return SummaryStats{}, fmt.Errorf("%w: %q", ErrInvalidSummary, r.Stats.Finished.Summary)
+	}
+
+	addresses, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return SummaryStats{}, fmt.Errorf("%w: %w", ErrInvalidSummary, err)
+	}
+
+	hostsUp, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return SummaryStats{}, fmt.Errorf("%w: %w", ErrInvalidSummary, err)
+	}
+
+	elapsed, err := strconv.ParseFloat(matches[3], 32)
+	if err != nil {
+		return SummaryStats{}, fmt.Errorf("%w: %w", ErrInvalidSummary, err)
+	}
+
+	return SummaryStats{
+		Addresses: addresses,
+		HostsUp:   hostsUp,
+		Elapsed:   float32(elapsed),
+	}, nil
+}
+
 // Timestamp represents time as a UNIX timestamp in seconds.
 type Timestamp time.Time
 
@@ -441,11 +1172,132 @@ func (t *Timestamp) UnmarshalXMLAttr(attr xml.Attr) (err error) {
 	return t.ParseTime(attr.Value)
 }
 
+// ReasonTTL is the time-to-live of the response that determined a host or port state.
+// It is zero when nmap did not report it, or reported a value that isn't a number.
+type ReasonTTL float32
+
+// UnmarshalXMLAttr implements the xml.UnmarshalXMLAttr interface.
+// Invalid values are ignored, since nmap versions differ in what they report.
+func (r *ReasonTTL) UnmarshalXMLAttr(attr xml.Attr) error {
+	ttl, err := strconv.ParseFloat(strings.TrimSpace(attr.Value), 32)
+	if err != nil {
+		*r = 0
+		return nil
+	}
+
+	*r = ReasonTTL(ttl)
+
+	return nil
+}
+
+// parseSnippetRadius is the amount of bytes kept around the position of a
+// parsing error in ErrParse snippets.
+const parseSnippetRadius = 40
+
+// ErrParse is returned when nmap's XML output can't be parsed, which commonly
+// happens when the output was truncated. It contains the byte offset at which
+// parsing failed, along with the XML surrounding it.
+type ErrParse struct {
+	Offset  int64
+	Snippet string
+	Err     error
+}
+
+func (e *ErrParse) Error() string {
+	return fmt.Sprintf("unable to parse nmap XML at offset %d near %q: %v", e.Offset, e.Snippet, e.Err)
+}
+
+// Unwrap returns the underlying XML error.
+func (e *ErrParse) Unwrap() error {
+	return e.Err
+}
+
+// newErrParse creates an ErrParse for an error that happened at the given offset of the content.
+func newErrParse(content []byte, offset int64, err error) *ErrParse {
+	start := offset - parseSnippetRadius
+	if start < 0 {
+		start = 0
+	}
+
+	end := offset + parseSnippetRadius
+	if end > int64(len(content)) {
+		end = int64(len(content))
+	}
+	if start > end {
+		start = end
+	}
+
+	return &ErrParse{
+		Offset:  offset,
+		Snippet: string(content[start:end]),
+		Err:     err,
+	}
+}
+
+// latestRun returns the last run of XML content in which several runs were
+// appended, such as output files written using WithAppendOutput.
+func latestRun(content []byte) []byte {
+	index := bytes.LastIndex(content, []byte("<nmaprun"))
+	if index <= 0 {
+		return content
+	}
+
+	return content[index:]
+}
+
 // Parse takes a byte array of nmap xml data and unmarshal it into a Run struct.
+// If the data can't be parsed, an *ErrParse is returned.
 func Parse(content []byte, result *Run) error {
+	return parse(content, result, 0)
+}
+
+// ParseReader reads nmap xml data from the given reader and unmarshals it into a Run struct,
+// without holding the whole data in memory. Unlike Parse, the raw XML is not kept, so
+// ToReader returns no data, and ToFile marshals the fields of the result.
+// If the data can't be parsed, an *ErrParse is returned, without a snippet of the data.
+func ParseReader(r io.Reader, result *Run) error {
+	return parseReader(r, result, 0)
+}
+
+// parseReader unmarshals nmap's XML output read from r into the given Run, keeping
+// at most maxHosts hosts, or all of them if maxHosts is zero. The hosts past the
+// limit are skipped while reading, so that they are never unmarshalled.
+func parseReader(r io.Reader, result *Run, maxHosts int) error {
+	limited := limitedRun{Run: *result, Hosts: limitedHosts{hosts: result.Hosts, max: maxHosts}}
+
+	decoder := xml.NewDecoder(r)
+	err := decoder.Decode(&limited)
+
+	*result = limited.Run
+	result.XMLName = limited.XMLName
+	result.Hosts = limited.Hosts.hosts
+	result.truncated = limited.Hosts.truncated
+	if len(result.ScanInfos) > 0 {
+		result.ScanInfo = result.ScanInfos[0]
+	}
+	if err != nil {
+		return newErrParse(nil, decoder.InputOffset(), err)
+	}
+
+	return nil
+}
+
+// parse unmarshals nmap's XML output into the given Run, keeping at most
+// maxHosts hosts, or all of them if maxHosts is zero.
+func parse(content []byte, result *Run, maxHosts int) error {
+	if maxHosts > 0 {
+		content, result.truncated = limitHosts(content, maxHosts)
+	}
 	result.rawXML = content
 
-	err := xml.Unmarshal(content, result)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	err := decoder.Decode(result)
+	if len(result.ScanInfos) > 0 {
+		result.ScanInfo = result.ScanInfos[0]
+	}
+	if err != nil {
+		return newErrParse(content, decoder.InputOffset(), err)
+	}
 
-	return err
+	return nil
 }
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -312,6 +315,55 @@ func TestToFile(t *testing.T) {
 	}
 }
 
+func TestToFileMergedRun(t *testing.T) {
+	var runs []*Run
+	for _, inputFile := range []string{"tests/xml/scan_base.xml", "tests/xml/scan_down_hosts.xml"} {
+		rawXML, err := os.ReadFile(inputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result Run
+		if err := Parse(rawXML, &result); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, &result)
+	}
+
+	merged := MergeRuns(runs...)
+
+	filePath := filepath.Join(t.TempDir(), "merged.xml")
+	if err := merged.ToFile(filePath); err != nil {
+		t.Fatalf("unexpected error writing merged run: %v", err)
+	}
+
+	rawXML, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rawXML) == 0 {
+		t.Fatal("expected merged run to be written, got empty file")
+	}
+
+	var result Run
+	if err := Parse(rawXML, &result); err != nil {
+		t.Fatalf("unable to parse merged run: %v", err)
+	}
+
+	if len(result.Hosts) != len(merged.Hosts) {
+		t.Errorf("expected %d hosts, got %d", len(merged.Hosts), len(result.Hosts))
+	}
+
+	if result.Stats.Hosts != merged.Stats.Hosts {
+		t.Errorf("expected host stats %+v, got %+v", merged.Stats.Hosts, result.Stats.Hosts)
+	}
+
+	if !time.Time(result.Start).Equal(time.Time(merged.Start)) {
+		t.Errorf("expected start time %v, got %v", time.Time(merged.Start), time.Time(result.Start))
+	}
+}
+
 func TestToReader(t *testing.T) {
 	inputFile := "tests/xml/scan_base.xml"
 	rawXML, err := ioutil.ReadFile(inputFile)
@@ -336,6 +388,1211 @@ func TestToReader(t *testing.T) {
 	}
 }
 
+func TestWriteCSV(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result.Hosts = append(result.Hosts, Host{
+		Addresses: []Address{{Addr: "192.168.1.1"}},
+	})
+
+	var buf bytes.Buffer
+	err = result.WriteCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "host,port,protocol,state,service,product,version\n" +
+		"66.35.250.168,80,tcp,open,http,Apache httpd,1.3.39\n" +
+		"66.35.250.168,443,tcp,closed,https,,\n" +
+		"192.168.1.1,,,,,,\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV output, expected %q got %q", expected, buf.String())
+	}
+}
+
+func TestWriteCSVQuoting(t *testing.T) {
+	result := Run{
+		Hosts: []Host{
+			{
+				Addresses: []Address{{Addr: "10.0.0.1"}},
+				Ports: []Port{
+					{
+						ID:       22,
+						Protocol: "tcp",
+						State:    State{State: "open"},
+						Service:  Service{Name: "ssh", Product: "OpenSSH, \"portable\"", Version: "8.9p1"},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "host,port,protocol,state,service,product,version\n" +
+		"10.0.0.1,22,tcp,open,ssh,\"OpenSSH, \"\"portable\"\"\",8.9p1\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected CSV output, expected %q got %q", expected, buf.String())
+	}
+}
+
+func TestParseMultipleScanInfo(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_tcp_udp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedScanInfos := []ScanInfo{
+		{
+			NumServices: 2,
+			Protocol:    "tcp",
+			Services:    "22,80",
+			Type:        "syn",
+		},
+		{
+			NumServices: 2,
+			Protocol:    "udp",
+			Services:    "53,161",
+			Type:        "udp",
+		},
+	}
+
+	if !reflect.DeepEqual(result.ScanInfos, expectedScanInfos) {
+		t.Errorf("unexpected scan infos, expected %+v got %+v", expectedScanInfos, result.ScanInfos)
+	}
+
+	if !reflect.DeepEqual(result.ScanInfo, expectedScanInfos[0]) {
+		t.Errorf("expected scan info to be the first scan info %+v, got %+v", expectedScanInfos[0], result.ScanInfo)
+	}
+}
+
+func TestPortProto(t *testing.T) {
+	tests := []struct {
+		protocol string
+		expected TransportProtocol
+	}{
+		{protocol: "tcp", expected: TCP},
+		{protocol: "TCP", expected: TCP},
+		{protocol: "udp", expected: UDP},
+		{protocol: "Udp", expected: UDP},
+		{protocol: "sctp", expected: SCTP},
+		{protocol: "ip", expected: IP},
+	}
+
+	for _, test := range tests {
+		t.Run(test.protocol, func(t *testing.T) {
+			p := Port{Protocol: test.protocol}
+
+			if p.Proto() != test.expected {
+				t.Errorf("expected protocol %q to map to %q, got %q", test.protocol, test.expected, p.Proto())
+			}
+		})
+	}
+}
+
+func TestPortProtocols(t *testing.T) {
+	rawXML := []byte(`<nmaprun><host><ports>` +
+		`<port protocol="udp" portid="111"><state state="open" reason="udp-response"/>` +
+		`<service name="rpcbind" proto="rpc" rpcnum="100000" lowver="2" highver="4" method="probed" conf="10"/></port>` +
+		`<port protocol="tcp" portid="22"><state state="open" reason="syn-ack"/><service name="ssh" method="table" conf="3"/></port>` +
+		`</ports></host></nmaprun>`)
+
+	var result Run
+	if err := Parse(rawXML, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+
+		port Port
+
+		expectedTransport TransportProtocol
+		expectedService   string
+	}{
+		{
+			description: "RPC service",
+
+			port: result.Hosts[0].Ports[0],
+
+			expectedTransport: UDP,
+			expectedService:   "rpc",
+		},
+		{
+			description: "non-RPC service",
+
+			port: result.Hosts[0].Ports[1],
+
+			expectedTransport: TCP,
+			expectedService:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if transport := test.port.TransportProtocol(); transport != test.expectedTransport {
+				t.Errorf("expected transport protocol %q, got %q", test.expectedTransport, transport)
+			}
+
+			if service := test.port.ServiceProtocol(); service != test.expectedService {
+				t.Errorf("expected service protocol %q, got %q", test.expectedService, service)
+			}
+		})
+	}
+}
+
+func TestHostCount(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_down_hosts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.HostCount() != 4 {
+		t.Errorf("expected 4 hosts, got %d", result.HostCount())
+	}
+
+	if !result.HasHosts() {
+		t.Error("expected run to have hosts")
+	}
+
+	var empty Run
+	if empty.HostCount() != 0 || empty.HasHosts() {
+		t.Errorf("expected empty run to have no hosts, got %d", empty.HostCount())
+	}
+}
+
+func TestRunWarnings(t *testing.T) {
+	tests := []struct {
+		description string
+
+		warnings []string
+
+		expectedHasWarnings  bool
+		expectedWarningCount int
+	}{
+		{
+			description: "no warnings",
+
+			expectedHasWarnings:  false,
+			expectedWarningCount: 0,
+		},
+		{
+			description: "warnings",
+
+			warnings: []string{"Starting Nmap 7.93", `Failed to resolve "domain.does.not.exist".`},
+
+			expectedHasWarnings:  true,
+			expectedWarningCount: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result := Run{warnings: test.warnings}
+
+			if result.HasWarnings() != test.expectedHasWarnings {
+				t.Errorf("expected HasWarnings to return %t", test.expectedHasWarnings)
+			}
+
+			if result.WarningCount() != test.expectedWarningCount {
+				t.Errorf("expected %d warnings, got %d", test.expectedWarningCount, result.WarningCount())
+			}
+		})
+	}
+}
+
+func TestRunTimes(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_tcp_udp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Unix(1684341000, 0); !result.StartedAt().Equal(expected) {
+		t.Errorf("expected scan to start at %v, got %v", expected, result.StartedAt())
+	}
+
+	if expected := time.Unix(1684341005, 0); !result.FinishedAt().Equal(expected) {
+		t.Errorf("expected scan to finish at %v, got %v", expected, result.FinishedAt())
+	}
+
+	if !(Run{}).FinishedAt().IsZero() {
+		t.Error("expected finish time of an empty run to be zero")
+	}
+}
+
+func TestTLSService(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_ssl_tunnel.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports := result.Hosts[0].Ports
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 ports, got %d", len(ports))
+	}
+
+	if ports[0].IsTLS() || ports[0].Service.IsEncrypted() {
+		t.Errorf("expected port %d not to be using TLS", ports[0].ID)
+	}
+
+	if !ports[1].IsTLS() || !ports[1].Service.IsEncrypted() {
+		t.Errorf("expected port %d to be using TLS", ports[1].ID)
+	}
+}
+
+func TestServiceProbed(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports := result.Hosts[0].Ports
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 ports, got %d", len(ports))
+	}
+
+	if !ports[0].ServiceProbed() {
+		t.Errorf("expected service on port %d to be probed", ports[0].ID)
+	}
+
+	if ports[1].ServiceProbed() {
+		t.Errorf("expected service on port %d to be guessed from the services table", ports[1].ID)
+	}
+}
+
+func TestServiceFullDescription(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+
+		service Service
+
+		expectedDescription string
+	}{
+		{
+			description: "apache sample service",
+
+			service: result.Hosts[0].Ports[0].Service,
+
+			expectedDescription: "Apache httpd 1.3.39 ((Unix) PHP/4.4.7)",
+		},
+		{
+			description: "service without version information",
+
+			service: result.Hosts[0].Ports[1].Service,
+
+			expectedDescription: "",
+		},
+		{
+			description: "service with OS type",
+
+			service: Service{Product: "OpenSSH", Version: "7.4", ExtraInfo: "protocol 2.0", OSType: "Linux"},
+
+			expectedDescription: "OpenSSH 7.4 (protocol 2.0); OS: Linux",
+		},
+		{
+			description: "service with OS type only",
+
+			service: Service{OSType: "Windows"},
+
+			expectedDescription: "OS: Windows",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if description := test.service.FullDescription(); description != test.expectedDescription {
+				t.Errorf("expected description %q, got %q", test.expectedDescription, description)
+			}
+		})
+	}
+}
+
+func TestParseErrorContext(t *testing.T) {
+	tests := []struct {
+		description string
+
+		content []byte
+
+		expectedOffset  int64
+		expectedSnippet string
+	}{
+		{
+			description: "truncated output",
+
+			content: []byte(`<nmaprun scanner="nmap"><host><status state="up"/></ho`),
+
+			expectedOffset:  54,
+			expectedSnippet: `er="nmap"><host><status state="up"/></ho`,
+		},
+		{
+			description: "mismatched element",
+
+			content: []byte(`<nmaprun scanner="nmap"><host></port></nmaprun>`),
+
+			expectedOffset:  37,
+			expectedSnippet: `<nmaprun scanner="nmap"><host></port></nmaprun>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			var result Run
+			err := Parse(test.content, &result)
+
+			var parseErr *ErrParse
+			if !errors.As(err, &parseErr) {
+				t.Fatalf("expected an *ErrParse, got %v", err)
+			}
+
+			if parseErr.Offset != test.expectedOffset {
+				t.Errorf("expected offset %d, got %d", test.expectedOffset, parseErr.Offset)
+			}
+
+			if parseErr.Snippet != test.expectedSnippet {
+				t.Errorf("expected snippet %q, got %q", test.expectedSnippet, parseErr.Snippet)
+			}
+
+			var syntaxErr *xml.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Errorf("expected the XML syntax error to be wrapped, got %v", parseErr.Err)
+			}
+		})
+	}
+}
+
+func TestTraceHops(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := result.Hosts[0].Trace
+
+	namedHops := trace.NamedHops()
+	if len(namedHops) != 13 {
+		t.Errorf("expected 13 named hops, got %d", len(namedHops))
+	}
+
+	for _, hop := range namedHops {
+		if hop.Host == "" {
+			t.Errorf("expected named hops to have a host, got %+v", hop)
+		}
+	}
+
+	if namedHops[0].IPAddr != "200.217.30.250" {
+		t.Errorf("expected first named hop to be 200.217.30.250, got %s", namedHops[0].IPAddr)
+	}
+
+	lastHop := trace.LastHop()
+	if lastHop == nil || lastHop.Host != "freshmeat.net" || lastHop.TTL != 18 {
+		t.Errorf("expected last hop to be freshmeat.net at TTL 18, got %+v", lastHop)
+	}
+
+	if (Trace{}).LastHop() != nil {
+		t.Error("expected empty trace to have no last hop")
+	}
+
+	if (Trace{}).NamedHops() != nil {
+		t.Error("expected empty trace to have no named hops")
+	}
+}
+
+func TestPortStatusFiltered(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_tcp_udp.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host := result.Hosts[0]
+
+	statuses := make(map[uint16]PortStatus)
+	for _, port := range host.Ports {
+		statuses[port.ID] = port.Status()
+	}
+
+	expectedStatuses := map[uint16]PortStatus{
+		22:  Open,
+		80:  Closed,
+		53:  Open,
+		161: OpenFiltered,
+	}
+	if !reflect.DeepEqual(statuses, expectedStatuses) {
+		t.Errorf("expected port statuses %v, got %v", expectedStatuses, statuses)
+	}
+
+	var openPorts []uint16
+	for _, port := range host.OpenPorts() {
+		openPorts = append(openPorts, port.ID)
+	}
+
+	if expected := []uint16{22, 53}; !reflect.DeepEqual(openPorts, expected) {
+		t.Errorf("expected open ports %v, got %v", expected, openPorts)
+	}
+}
+
+func TestReasonTTL(t *testing.T) {
+	tests := []struct {
+		description string
+
+		attribute string
+
+		expectedTTL ReasonTTL
+	}{
+		{
+			description: "present",
+
+			attribute: `reason_ttl="64"`,
+
+			expectedTTL: 64,
+		},
+		{
+			description: "absent",
+
+			attribute: "",
+
+			expectedTTL: 0,
+		},
+		{
+			description: "empty",
+
+			attribute: `reason_ttl=""`,
+
+			expectedTTL: 0,
+		},
+		{
+			description: "not a number",
+
+			attribute: `reason_ttl="—"`,
+
+			expectedTTL: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rawXML := fmt.Sprintf(`<nmaprun><host><status state="up" reason="echo-reply" %[1]s/>`+
+				`<ports><port protocol="tcp" portid="22"><state state="open" reason="syn-ack" %[1]s/></port></ports>`+
+				`</host></nmaprun>`, test.attribute)
+
+			var result Run
+			if err := Parse([]byte(rawXML), &result); err != nil {
+				t.Fatal(err)
+			}
+
+			host := result.Hosts[0]
+			if host.Status.ReasonTTL != test.expectedTTL {
+				t.Errorf("expected host reason TTL %v, got %v", test.expectedTTL, host.Status.ReasonTTL)
+			}
+
+			if host.Ports[0].State.ReasonTTL != test.expectedTTL {
+				t.Errorf("expected port reason TTL %v, got %v", test.expectedTTL, host.Ports[0].State.ReasonTTL)
+			}
+		})
+	}
+}
+
+func TestEstimatedHopDistance(t *testing.T) {
+	tests := []struct {
+		description string
+
+		host Host
+
+		expectedDistance int
+	}{
+		{
+			description: "distance reported by nmap",
+
+			host: Host{
+				Distance: Distance{Value: 3},
+				Status:   Status{ReasonTTL: 64},
+			},
+
+			expectedDistance: 3,
+		},
+		{
+			description: "local linux host",
+
+			host: Host{Status: Status{ReasonTTL: 64}},
+
+			expectedDistance: 1,
+		},
+		{
+			description: "remote linux host",
+
+			host: Host{Status: Status{ReasonTTL: 45}},
+
+			expectedDistance: 20,
+		},
+		{
+			description: "remote windows host",
+
+			host: Host{Status: Status{ReasonTTL: 120}},
+
+			expectedDistance: 9,
+		},
+		{
+			description: "remote network device",
+
+			host: Host{Status: Status{ReasonTTL: 250}},
+
+			expectedDistance: 6,
+		},
+		{
+			description: "TTL of a port response",
+
+			host: Host{
+				Ports: []Port{
+					{State: State{ReasonTTL: 0}},
+					{State: State{ReasonTTL: 126}},
+				},
+			},
+
+			expectedDistance: 3,
+		},
+		{
+			description: "no TTL",
+
+			host: Host{Status: Status{Reason: "arp-response"}},
+
+			expectedDistance: -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			if distance := test.host.EstimatedHopDistance(); distance != test.expectedDistance {
+				t.Errorf("expected distance %d, got %d", test.expectedDistance, distance)
+			}
+		})
+	}
+}
+
+func TestOSCPEs(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_os_matches.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCPEs := []CPE{"cpe:/o:linux:linux_kernel:5", "cpe:/o:linux:linux_kernel:5.4"}
+	if cpes := result.Hosts[0].OSCPEs(); !reflect.DeepEqual(cpes, expectedCPEs) {
+		t.Errorf("expected CPEs %v, got %v", expectedCPEs, cpes)
+	}
+
+	if cpes := (Host{}).OSCPEs(); cpes != nil {
+		t.Errorf("expected no CPEs without OS match, got %v", cpes)
+	}
+}
+
+func TestDownHosts(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_down_hosts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Hosts) != 4 {
+		t.Fatalf("expected down hosts to be parsed, got %d hosts", len(result.Hosts))
+	}
+
+	downHosts := result.DownHosts()
+	if len(downHosts) != result.Stats.Hosts.Down {
+		t.Fatalf("expected %d down hosts, got %d", result.Stats.Hosts.Down, len(downHosts))
+	}
+
+	for idx, expectedAddr := range []string{"192.168.1.1", "192.168.1.3"} {
+		if downHosts[idx].Addresses[0].Addr != expectedAddr {
+			t.Errorf("expected down host %s, got %s", expectedAddr, downHosts[idx].Addresses[0].Addr)
+		}
+
+		if downHosts[idx].Status.Reason != "no-response" {
+			t.Errorf("expected down host reason to be no-response, got %s", downHosts[idx].Status.Reason)
+		}
+	}
+}
+
+func TestAddressesByType(t *testing.T) {
+	rawXML := []byte(`<nmaprun><host><status state="up" reason="nd-response"/>` +
+		`<address addr="192.168.1.10" addrtype="ipv4"/>` +
+		`<address addr="2001:db8::10" addrtype="ipv6"/>` +
+		`<address addr="fe80::10" addrtype="ipv6"/>` +
+		`<address addr="00:11:22:33:44:55" addrtype="mac" vendor="Cisco Systems"/>` +
+		`</host></nmaprun>`)
+
+	var result Run
+	if err := Parse(rawXML, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	host := result.Hosts[0]
+	expectedAddresses := []Address{
+		{Addr: "192.168.1.10", AddrType: "ipv4"},
+		{Addr: "2001:db8::10", AddrType: "ipv6"},
+		{Addr: "fe80::10", AddrType: "ipv6"},
+		{Addr: "00:11:22:33:44:55", AddrType: "mac", Vendor: "Cisco Systems"},
+	}
+	if !reflect.DeepEqual(host.Addresses, expectedAddresses) {
+		t.Fatalf("expected addresses %v, got %v", expectedAddresses, host.Addresses)
+	}
+
+	tests := []struct {
+		addrType string
+
+		expectedAddresses []Address
+	}{
+		{
+			addrType: "ipv4",
+
+			expectedAddresses: expectedAddresses[:1],
+		},
+		{
+			addrType: "ipv6",
+
+			expectedAddresses: expectedAddresses[1:3],
+		},
+		{
+			addrType: "mac",
+
+			expectedAddresses: expectedAddresses[3:],
+		},
+		{
+			addrType: "unknown",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addrType, func(t *testing.T) {
+			addresses := host.AddressesByType(test.addrType)
+			if !reflect.DeepEqual(addresses, test.expectedAddresses) {
+				t.Errorf("expected addresses %v, got %v", test.expectedAddresses, addresses)
+			}
+		})
+	}
+}
+
+func TestExcludeCIDR(t *testing.T) {
+	newHost := func(addresses ...Address) Host {
+		return Host{Addresses: addresses}
+	}
+
+	tests := []struct {
+		description string
+
+		cidrs []string
+
+		expectedAddrs []string
+		expectedErr   bool
+	}{
+		{
+			description: "exclude a /24",
+
+			cidrs: []string{"192.168.1.0/24"},
+
+			expectedAddrs: []string{"10.0.0.5", "2001:db8::1", "00:11:22:33:44:55"},
+		},
+		{
+			description: "exclude multiple CIDRs",
+
+			cidrs: []string{"192.168.1.0/25", "2001:db8::/32"},
+
+			expectedAddrs: []string{"10.0.0.5", "192.168.1.200", "00:11:22:33:44:55"},
+		},
+		{
+			description: "no CIDR",
+
+			expectedAddrs: []string{"10.0.0.5", "192.168.1.1", "192.168.1.200", "2001:db8::1", "00:11:22:33:44:55"},
+		},
+		{
+			description: "invalid CIDR",
+
+			cidrs: []string{"192.168.1.0/24", "192.168.1.1"},
+
+			expectedAddrs: []string{"10.0.0.5", "192.168.1.1", "192.168.1.200", "2001:db8::1", "00:11:22:33:44:55"},
+			expectedErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result := Run{
+				Hosts: []Host{
+					newHost(Address{Addr: "10.0.0.5", AddrType: "ipv4"}),
+					newHost(Address{Addr: "192.168.1.1", AddrType: "ipv4"}, Address{Addr: "AA:BB:CC:DD:EE:FF", AddrType: "mac"}),
+					newHost(Address{Addr: "192.168.1.200", AddrType: "ipv4"}),
+					newHost(Address{Addr: "2001:db8::1", AddrType: "ipv6"}),
+					newHost(Address{Addr: "00:11:22:33:44:55", AddrType: "mac"}),
+				},
+			}
+
+			err := result.ExcludeCIDR(test.cidrs...)
+			if (err != nil) != test.expectedErr {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			var addrs []string
+			for _, host := range result.Hosts {
+				addrs = append(addrs, host.Addresses[0].Addr)
+			}
+
+			if !reflect.DeepEqual(addrs, test.expectedAddrs) {
+				t.Errorf("expected hosts %v, got %v", test.expectedAddrs, addrs)
+			}
+		})
+	}
+}
+
+func TestSortHosts(t *testing.T) {
+	newHost := func(address Address, openPorts int) Host {
+		host := Host{Addresses: []Address{address}}
+		for i := 0; i < openPorts; i++ {
+			host.Ports = append(host.Ports, Port{ID: uint16(i + 1), Protocol: "tcp", State: State{State: "open"}})
+		}
+		host.Ports = append(host.Ports, Port{ID: 1000, Protocol: "tcp", State: State{State: "closed"}})
+		return host
+	}
+
+	tests := []struct {
+		description string
+
+		sort func(r *Run)
+
+		expectedAddrs []string
+	}{
+		{
+			description: "sort by address",
+
+			sort: (*Run).SortHostsByAddress,
+
+			expectedAddrs: []string{
+				"9.9.9.9",
+				"10.0.0.2",
+				"10.0.0.10",
+				"192.168.1.1",
+				"::1",
+				"2001:db8::2",
+				"2001:db8::10",
+				"00:11:22:33:44:55",
+			},
+		},
+		{
+			description: "sort by open ports",
+
+			sort: (*Run).SortHostsByOpenPorts,
+
+			expectedAddrs: []string{
+				"10.0.0.10",
+				"2001:db8::10",
+				"192.168.1.1",
+				"9.9.9.9",
+				"10.0.0.2",
+				"::1",
+				"2001:db8::2",
+				"00:11:22:33:44:55",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result := Run{
+				Hosts: []Host{
+					newHost(Address{Addr: "2001:db8::10", AddrType: "ipv6"}, 3),
+					newHost(Address{Addr: "10.0.0.10", AddrType: "ipv4"}, 3),
+					newHost(Address{Addr: "00:11:22:33:44:55", AddrType: "mac"}, 0),
+					newHost(Address{Addr: "192.168.1.1", AddrType: "ipv4"}, 2),
+					newHost(Address{Addr: "10.0.0.2", AddrType: "ipv4"}, 1),
+					newHost(Address{Addr: "2001:db8::2", AddrType: "ipv6"}, 0),
+					newHost(Address{Addr: "9.9.9.9", AddrType: "ipv4"}, 1),
+					newHost(Address{Addr: "::1", AddrType: "ipv6"}, 0),
+				},
+			}
+
+			test.sort(&result)
+
+			var addrs []string
+			for _, host := range result.Hosts {
+				addrs = append(addrs, host.Addresses[0].Addr)
+			}
+
+			if !reflect.DeepEqual(addrs, test.expectedAddrs) {
+				t.Errorf("expected hosts %v, got %v", test.expectedAddrs, addrs)
+			}
+		})
+	}
+}
+
+func TestKeepUpHosts(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_down_hosts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upHosts := result.UpHosts()
+	if len(upHosts) != result.Stats.Hosts.Up {
+		t.Fatalf("expected %d up hosts, got %d", result.Stats.Hosts.Up, len(upHosts))
+	}
+
+	if len(result.Hosts) != 4 {
+		t.Fatalf("expected UpHosts not to modify the run, got %d hosts", len(result.Hosts))
+	}
+
+	result.KeepUpHosts()
+	if !reflect.DeepEqual(result.Hosts, upHosts) {
+		t.Fatalf("expected only up hosts to be kept, got %v", result.Hosts)
+	}
+
+	for idx, expectedAddr := range []string{"192.168.1.2", "192.168.1.4"} {
+		if result.Hosts[idx].Addresses[0].Addr != expectedAddr {
+			t.Errorf("expected up host %s, got %s", expectedAddr, result.Hosts[idx].Addresses[0].Addr)
+		}
+	}
+}
+
+func TestListedTargets(t *testing.T) {
+	tests := []struct {
+		description string
+
+		inputFile string
+
+		expectedTargets []string
+	}{
+		{
+			description: "list scan of a network",
+
+			inputFile: "tests/xml/scan_list.xml",
+
+			expectedTargets: []string{"192.168.1.0", "192.168.1.1", "192.168.1.3"},
+		},
+		{
+			description: "list scan of hostnames and addresses",
+
+			inputFile: "tests/xml/scan_list_hostnames.xml",
+
+			expectedTargets: []string{"scanme.nmap.org", "192.168.1.1", "2001:db8::1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			rawXML, err := ioutil.ReadFile(test.inputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result Run
+			err = Parse(rawXML, &result)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(result.ListedTargets(), test.expectedTargets) {
+				t.Errorf("expected targets %v, got %v", test.expectedTargets, result.ListedTargets())
+			}
+		})
+	}
+}
+
+func TestParseSummary(t *testing.T) {
+	tests := []struct {
+		description string
+
+		summary string
+
+		expectedStats SummaryStats
+		expectedErr   error
+	}{
+		{
+			description: "several hosts",
+
+			summary: "Nmap done at Sun Jan 27 21:52:49 2008; 8 IP addresses (8 hosts up) scanned in 2567.06 seconds",
+
+			expectedStats: SummaryStats{Addresses: 8, HostsUp: 8, Elapsed: 2567.06},
+		},
+		{
+			description: "single host",
+
+			summary: "Nmap done at Wed May 17 18:30:10 2023; 1 IP address (1 host up) scanned in 10.02 seconds",
+
+			expectedStats: SummaryStats{Addresses: 1, HostsUp: 1, Elapsed: 10.02},
+		},
+		{
+			description: "no host up",
+
+			summary: "Nmap done at Wed May 17 18:30:00 2023; 256 IP addresses (0 hosts up) scanned in 3 seconds",
+
+			expectedStats: SummaryStats{Addresses: 256, HostsUp: 0, Elapsed: 3},
+		},
+		{
+			description: "missing summary",
+
+			expectedErr: ErrInvalidSummary,
+		},
+		{
+			description: "unexpected summary",
+
+			summary: "Nmap done at Wed May 17 18:30:00 2023; scan aborted",
+
+			expectedErr: ErrInvalidSummary,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			result := Run{Stats: Stats{Finished: Finished{Summary: test.summary}}}
+
+			stats, err := result.ParseSummary()
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+
+			if stats != test.expectedStats {
+				t.Errorf("expected stats %+v, got %+v", test.expectedStats, stats)
+			}
+		})
+	}
+}
+
+func TestAllScripts(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_all_scripts.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scripts := result.AllScripts()
+
+	counts := make(map[ScriptContext]int)
+	var locations []string
+	for _, ref := range scripts {
+		counts[ref.Context]++
+		locations = append(locations, fmt.Sprintf("%s %s %s:%d/%s", ref.Context, ref.Script.ID, ref.Host, ref.Port, ref.Protocol))
+	}
+
+	expectedCounts := map[ScriptContext]int{
+		ScriptContextPreScan:  1,
+		ScriptContextHost:     2,
+		ScriptContextPort:     3,
+		ScriptContextPostScan: 1,
+	}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("expected script counts %v, got %v", expectedCounts, counts)
+	}
+
+	expectedLocations := []string{
+		"prescan broadcast-ping :0/",
+		"host smb2-time 192.168.1.1:0/",
+		"port ssh-hostkey 192.168.1.1:22/tcp",
+		"port ssh2-enum-algos 192.168.1.1:22/tcp",
+		"port http-title 192.168.1.1:80/tcp",
+		"host nbstat 192.168.1.2:0/",
+		"postscan ssh-hostkey :0/",
+	}
+	if !reflect.DeepEqual(locations, expectedLocations) {
+		t.Errorf("expected script locations %v, got %v", expectedLocations, locations)
+	}
+
+	if scripts[4].Script.Elements[0].Value != "Router login" {
+		t.Errorf("expected script results to be kept, got %+v", scripts[4].Script)
+	}
+}
+
+func TestCPEParse(t *testing.T) {
+	tests := []struct {
+		description string
+
+		cpe CPE
+
+		expectedComponents CPEComponents
+		expectedErr        error
+	}{
+		{
+			description: "CPE 2.2 URI",
+
+			cpe: "cpe:/a:openbsd:openssh:8.9p1",
+
+			expectedComponents: CPEComponents{Part: "a", Vendor: "openbsd", Product: "openssh", Version: "8.9p1"},
+		},
+		{
+			description: "CPE 2.2 URI without version",
+
+			cpe: "cpe:/o:linux:linux_kernel",
+
+			expectedComponents: CPEComponents{Part: "o", Vendor: "linux", Product: "linux_kernel"},
+		},
+		{
+			description: "CPE 2.2 URI with update and percent-encoding",
+
+			cpe: "cpe:/a:hp:insight_diagnostics:7.4.0.1570:%7eonline",
+
+			expectedComponents: CPEComponents{Part: "a", Vendor: "hp", Product: "insight_diagnostics", Version: "7.4.0.1570", Update: "~online"},
+		},
+		{
+			description: "CPE 2.3 formatted string",
+
+			cpe: "cpe:2.3:a:apache:http_server:2.4.52:*:*:*:*:*:*:*",
+
+			expectedComponents: CPEComponents{Part: "a", Vendor: "apache", Product: "http_server", Version: "2.4.52"},
+		},
+		{
+			description: "CPE 2.3 formatted string with escaped characters",
+
+			cpe: `cpe:2.3:h:cisco:asa\:5505:-:\*:*:*:*:*:*`,
+
+			expectedComponents: CPEComponents{Part: "h", Vendor: "cisco", Product: "asa:5505", Version: "-", Update: "*"},
+		},
+		{
+			description: "unknown format",
+
+			cpe: "openssh 8.9",
+
+			expectedErr: ErrInvalidCPE,
+		},
+		{
+			description: "unknown part",
+
+			cpe: "cpe:/x:openbsd:openssh",
+
+			expectedErr: ErrInvalidCPE,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			components, err := test.cpe.Parse()
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+
+			if components != test.expectedComponents {
+				t.Errorf("expected components %+v, got %+v", test.expectedComponents, components)
+			}
+		})
+	}
+}
+
+func TestUnidentifiedServices(t *testing.T) {
+	rawXML, err := ioutil.ReadFile("tests/xml/scan_unidentified_services.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result Run
+	err = Parse(rawXML, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ports := result.UnidentifiedServices()
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 unidentified services, got %d", len(ports))
+	}
+
+	for idx, expectedID := range []uint16{9999, 8000} {
+		if ports[idx].ID != expectedID {
+			t.Errorf("expected unidentified service on port %d, got %d", expectedID, ports[idx].ID)
+		}
+		if !strings.HasPrefix(ports[idx].Service.ServiceFP, fmt.Sprintf("SF-Port%d-TCP", expectedID)) {
+			t.Errorf("expected fingerprint of port %d, got %q", expectedID, ports[idx].Service.ServiceFP)
+		}
+	}
+
+	if result.Hosts[0].Ports[0].IsUnidentifiedService() {
+		t.Error("expected identified service not to be reported")
+	}
+	if result.Hosts[1].Ports[1].IsUnidentifiedService() {
+		t.Error("expected service guessed without fingerprint not to be reported")
+	}
+}
+
 func TestTimestampJSONMarshaling(t *testing.T) {
 	dateTime := time.Date(2000, 0, 0, 0, 0, 0, 0, time.UTC)
 	dateBytes := []byte("943920000")